@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// hashtagPattern matches #tag when the # starts a word, so URL fragments are skipped
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/#])#([\p{L}\p{N}_]*[\p{L}_][\p{L}\p{N}_]*)`)
+
+	// mentionPattern matches @user when the @ starts a word, so email addresses are skipped
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])@([\p{L}\p{N}_](?:[\p{L}\p{N}_.]*[\p{L}\p{N}_])?)`)
+)
+
+// ExtractHashtags returns the unique lowercase hashtags in text, without the #
+func ExtractHashtags(text string) []string {
+	return extractEntities(hashtagPattern, text)
+}
+
+// ExtractMentions returns the unique lowercase usernames mentioned in text, without the @
+func ExtractMentions(text string) []string {
+	return extractEntities(mentionPattern, text)
+}
+
+// extractEntities returns the first capture group of every match, lowercased and deduplicated
+func extractEntities(pattern *regexp.Regexp, text string) []string {
+	var entities []string
+	seen := make(map[string]bool)
+	for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+		entity := strings.ToLower(match[1])
+		if !seen[entity] {
+			seen[entity] = true
+			entities = append(entities, entity)
+		}
+	}
+	return entities
+}
+
+// EntityQuery is a search query split into free text and entity filters
+type EntityQuery struct {
+	Text     string   // Remaining words for full-text search
+	Hashtags []string // From #tag tokens
+	Mentions []string // From @user tokens
+}
+
+// ParseEntityQuery pulls #tag and @user tokens out of a search query
+func ParseEntityQuery(query string) EntityQuery {
+	var parsed EntityQuery
+	var words []string
+	for _, word := range strings.Fields(query) {
+		switch {
+		case len(word) > 1 && strings.HasPrefix(word, "#"):
+			parsed.Hashtags = append(parsed.Hashtags, ExtractHashtags(word)...)
+		case len(word) > 1 && strings.HasPrefix(word, "@"):
+			parsed.Mentions = append(parsed.Mentions, ExtractMentions(word)...)
+		default:
+			words = append(words, word)
+		}
+	}
+	parsed.Text = strings.Join(words, " ")
+	return parsed
+}
+
+// MergeUnique appends values not already present, comparing case-insensitively
+func MergeUnique(existing []string, values ...string) []string {
+	seen := make(map[string]bool, len(existing))
+	for _, value := range existing {
+		seen[strings.ToLower(value)] = true
+	}
+	for _, value := range values {
+		if key := strings.ToLower(value); !seen[key] {
+			seen[key] = true
+			existing = append(existing, value)
+		}
+	}
+	return existing
+}
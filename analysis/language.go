@@ -0,0 +1,133 @@
+package analysis
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// mongoLanguages maps supported ISO 639-1 codes to MongoDB text search languages.
+// MongoDB has no Arabic stemmer, so Arabic uses "none" and relies on NormalizeArabic.
+var mongoLanguages = map[string]string{
+	"en": "english",
+	"fr": "french",
+	"ar": "none",
+}
+
+// frenchAccents are letters that are common in French and rare in English
+const frenchAccents = "éèêëàâçùûôîïœ"
+
+// IsSupportedLanguage reports whether a language code can be detected and searched
+func IsSupportedLanguage(lang string) bool {
+	_, ok := mongoLanguages[lang]
+	return ok
+}
+
+// MongoLanguage returns the MongoDB text search language for a language code
+func MongoLanguage(lang string) string {
+	if language, ok := mongoLanguages[lang]; ok {
+		return language
+	}
+	return "english"
+}
+
+// DetectLanguage guesses the language of text as "ar", "fr" or "en". Arabic is
+// detected by script; Latin text by stopword and accent frequency. It returns ""
+// when the text has no letters.
+func DetectLanguage(text string) string {
+	arabic, latin, accents := 0, 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+			if strings.ContainsRune(frenchAccents, unicode.ToLower(r)) {
+				accents++
+			}
+		}
+	}
+
+	if arabic == 0 && latin == 0 {
+		return ""
+	}
+	if arabic > latin {
+		return "ar"
+	}
+
+	english, french := 0, accents
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		if stopwords["en"][word] {
+			english++
+		}
+		if stopwords["fr"][word] {
+			french++
+		}
+	}
+
+	if french > english {
+		return "fr"
+	}
+	return "en"
+}
+
+// arabicLetterVariants maps letter forms that users type interchangeably to one form
+var arabicLetterVariants = strings.NewReplacer(
+	"أ", "ا", "إ", "ا", "آ", "ا", "ٱ", "ا",
+	"ى", "ي", "ئ", "ي",
+	"ؤ", "و",
+	"ة", "ه",
+)
+
+// NormalizeArabic removes diacritics (tashkeel) and tatweel and unifies alef,
+// yaa, waw and taa marbuta variants
+func NormalizeArabic(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if (r >= 'ً' && r <= 'ٟ') || r == 'ٰ' || r == 'ـ' {
+			return -1
+		}
+		return r
+	}, text)
+	return arabicLetterVariants.Replace(text)
+}
+
+// latinLigatures expands letters that don't decompose into a base letter and a mark
+var latinLigatures = strings.NewReplacer("œ", "oe", "æ", "ae", "ß", "ss", "ø", "o", "đ", "d", "ł", "l")
+
+// FoldAccents strips accents from Latin letters, so "café" becomes "cafe"
+func FoldAccents(text string) string {
+	decomposed := norm.NFD.String(text)
+	folded := strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) && r < '؀' {
+			return -1
+		}
+		return r
+	}, decomposed)
+	return latinLigatures.Replace(norm.NFC.String(folded))
+}
+
+// Analyze lowercases text and applies the Arabic and Latin normalizations
+func Analyze(text string) string {
+	return FoldAccents(NormalizeArabic(strings.ToLower(text)))
+}
+
+// NormalizedVariants returns the analyzed form of every word that analysis
+// changes. Indexing and querying these variants lets "أحمد" match "احمد" and
+// "cafe" match "café" without storing a second copy of unchanged words.
+func NormalizedVariants(text string) []string {
+	var variants []string
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.Is(unicode.Mn, r)
+	}) {
+		analyzed := Analyze(word)
+		if analyzed != word && analyzed != "" && !seen[analyzed] {
+			seen[analyzed] = true
+			variants = append(variants, analyzed)
+		}
+	}
+	return variants
+}
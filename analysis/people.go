@@ -0,0 +1,31 @@
+package analysis
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// emailPattern matches email addresses
+var emailPattern = regexp.MustCompile(`[\p{L}\p{N}._%+\-]+@[\p{L}\p{N}\-]+(?:\.[\p{L}\p{N}\-]+)*\.\p{L}{2,}`)
+
+// RedactEmails removes email addresses from text
+func RedactEmails(text string) string {
+	if !strings.Contains(text, "@") {
+		return text
+	}
+	return emailPattern.ReplaceAllString(text, "")
+}
+
+// NormalizeUsername lowercases a username and strips a leading @
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+}
+
+// NameTokens returns the analyzed words of a display name, so "José Núñez"
+// becomes ["jose", "nunez"] and matches accent-free queries
+func NameTokens(name string) []string {
+	return strings.FieldsFunc(Analyze(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,175 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Phrase extraction tuning
+const (
+	maxCandidateTokens = 1000 // Only the start of very long content is analyzed
+	minTermLength      = 3    // Shorter single words are never phrases
+	minCorpusDocFreq   = 2    // A multi-word phrase seen once in this document must appear elsewhere
+)
+
+// WeightedText is a piece of a document whose occurrences count Weight times
+type WeightedText struct {
+	Text   string
+	Weight float64
+}
+
+// CorpusStats holds document frequencies for the candidates of one document
+type CorpusStats struct {
+	Docs    int            // Number of documents counted in the corpus
+	DocFreq map[string]int // Documents containing each term or phrase
+}
+
+// CountCandidates counts the single words, bigrams and trigrams of the texts
+// that could be key phrases. Stopwords, mentions and URLs never start or end a
+// phrase, and phrases never cross punctuation.
+func CountCandidates(lang string, texts ...WeightedText) map[string]float64 {
+	counts := make(map[string]float64)
+	tokens := 0
+	for _, text := range texts {
+		for _, sentence := range splitSentences(text.Text) {
+			if tokens >= maxCandidateTokens {
+				return counts
+			}
+			tokens += len(sentence)
+
+			for i, word := range sentence {
+				if isTermWord(lang, word) {
+					counts[word] += text.Weight
+				}
+				for n := 2; n <= 3 && i+n <= len(sentence); n++ {
+					gram := sentence[i : i+n]
+					if isEdgeWord(lang, gram[0]) && isEdgeWord(lang, gram[n-1]) {
+						counts[strings.Join(gram, " ")] += text.Weight
+					}
+				}
+			}
+		}
+	}
+	return counts
+}
+
+// ScorePhrases ranks candidates by TF-IDF against the corpus and keeps multi-word
+// phrases only when they are collocations: their pointwise mutual information
+// must be positive, and they must repeat in this document or the corpus.
+func ScorePhrases(counts map[string]float64, stats CorpusStats, limit int) []string {
+	docs := float64(stats.Docs + 1) // Include the document being indexed
+	probability := func(term string) float64 {
+		return float64(stats.DocFreq[term]+1) / (docs + 1)
+	}
+
+	type scored struct {
+		phrase string
+		score  float64
+	}
+	var ranked []scored
+	for phrase, tf := range counts {
+		idf := math.Log((docs+1)/float64(stats.DocFreq[phrase]+1)) + 1
+		score := (1 + math.Log(tf)) * idf
+
+		if words := strings.Fields(phrase); len(words) > 1 {
+			if tf < 2 && stats.DocFreq[phrase] < minCorpusDocFreq {
+				continue
+			}
+
+			joint := probability(phrase)
+			independent := 1.0
+			for _, word := range words {
+				independent *= probability(word)
+			}
+			pmi := math.Log(joint / independent)
+			if pmi <= 0 {
+				continue
+			}
+
+			// Normalized PMI is in (0, 1]; strong collocations score up to double
+			score *= 1 + pmi/-math.Log(joint)
+		}
+
+		ranked = append(ranked, scored{phrase: phrase, score: score})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].phrase < ranked[j].phrase
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	phrases := make([]string, len(ranked))
+	for i, r := range ranked {
+		phrases[i] = r.phrase
+	}
+	return phrases
+}
+
+// splitSentences lowercases text and splits it into runs of words that phrases
+// may span. Punctuation, mentions and URLs end a run.
+func splitSentences(text string) [][]string {
+	var sentences [][]string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			sentences = append(sentences, current)
+			current = nil
+		}
+	}
+
+	for _, field := range strings.Fields(strings.ToLower(text)) {
+		if strings.HasPrefix(field, "@") || strings.Contains(field, "://") || strings.HasPrefix(field, "www.") {
+			flush()
+			continue
+		}
+
+		// Split the field into words, ending the run at sentence punctuation
+		word := []rune{}
+		for _, r := range field {
+			switch {
+			case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r) || (r == '\'' && len(word) > 0):
+				word = append(word, r)
+			default:
+				if len(word) > 0 {
+					current = append(current, strings.TrimRight(string(word), "'"))
+					word = word[:0]
+				}
+				if r != '#' && r != '-' && r != '\'' {
+					flush()
+				}
+			}
+		}
+		if len(word) > 0 {
+			current = append(current, strings.TrimRight(string(word), "'"))
+		}
+	}
+	flush()
+	return sentences
+}
+
+// isTermWord reports whether a word can be a key phrase on its own
+func isTermWord(lang, word string) bool {
+	return len([]rune(word)) >= minTermLength && !IsStopwordIn(lang, word) && !isNumeric(word)
+}
+
+// isEdgeWord reports whether a word can start or end a multi-word phrase
+func isEdgeWord(lang, word string) bool {
+	return len([]rune(word)) >= 2 && !IsStopwordIn(lang, word) && !isNumeric(word)
+}
+
+// isNumeric reports whether a word is made only of digits
+func isNumeric(word string) bool {
+	for _, r := range word {
+		if !unicode.IsNumber(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package analysis
+
+import "strings"
+
+// stopwords are function words that carry no meaning on their own, keyed by
+// ISO 639-1 language code
+var stopwords = map[string]map[string]bool{
+	"en": toSet(`a about above after again against all am an and any are as at be because been
+before being below between both but by can could did do does doing down during each few for from
+further had has have having he her here hers herself him himself his how i if in into is it its
+itself just me more most my myself no nor not now of off on once only or other our ours ourselves
+out over own same she should so some such than that the their theirs them themselves then there
+these they this those through to too under until up very was we were what when where which while
+who whom why will with would you your yours yourself yourselves also get got like really much many
+one make made it's i'm don't can't that's`),
+	"fr": toSet(`a à au aux avec ce ces c'est cette dans de des du elle elles en est et être eu il
+ils je j'ai la le les leur leurs lui ma mais me même mes moi mon ne nos notre nous on ou où par
+pas pour qu que qui sa se ses son sont sur ta te tes toi ton tu un une vos votre vous y été était
+avait avoir fait faire plus très bien aussi comme tout tous toute toutes`),
+	"ar": toSet(`في من على إلى الى عن مع هذا هذه ذلك تلك التي الذي الذين هو هي هم هن أنا انا نحن
+أنت انت كان كانت يكون ما لا لم لن إن ان أن قد كل بعد قبل عند حتى او أو ثم بين كما لكن هل و يا
+غير ايضا أيضا منذ عليه عليها فيه فيها به بها له لها`),
+}
+
+// IsStopword reports whether a lowercase word is a stopword in any supported language
+func IsStopword(word string) bool {
+	word = strings.ToLower(word)
+	for _, words := range stopwords {
+		if words[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStopwordIn reports whether a lowercase word is a stopword in the given
+// language. Unknown or empty languages check every list.
+func IsStopwordIn(lang string, word string) bool {
+	words, ok := stopwords[lang]
+	if !ok {
+		return IsStopword(word)
+	}
+	return words[strings.ToLower(word)]
+}
+
+// AllStopwords reports whether every word in a phrase is a stopword
+func AllStopwords(phrase string) bool {
+	words := strings.Fields(phrase)
+	for _, word := range words {
+		if !IsStopword(word) {
+			return false
+		}
+	}
+	return len(words) > 0
+}
+
+// toSet splits a whitespace-separated word list into a lookup set
+func toSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"circleconnect-search/controllers"
+	"circleconnect-search/models"
+	"circleconnect-search/ranking"
+)
+
+// Backend runs a ranked query and returns content IDs in rank order
+type Backend interface {
+	Search(ctx context.Context, cfg ranking.Config, query, contentType string, k int) ([]string, error)
+	Close()
+}
+
+// mongoBackend runs the production pipeline against a search_index collection
+type mongoBackend struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+	now        time.Time
+}
+
+// newMongoBackend connects to MongoDB and selects the search_index collection
+func newMongoBackend(uri, dbName string, now time.Time) (*mongoBackend, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	return &mongoBackend{
+		client:     client,
+		collection: client.Database(dbName).Collection("search_index"),
+		now:        now,
+	}, nil
+}
+
+// Search runs the same filter and pipeline as a text mode Search by an anonymous
+// caller for the given config. Field weights are baked into the text index, so
+// only the boosts can differ.
+func (b *mongoBackend) Search(ctx context.Context, cfg ranking.Config, query, contentType string, k int) ([]string, error) {
+	filter := controllers.AnonymousSearchFilter(query, contentType)
+
+	cursor, err := b.collection.Aggregate(ctx, cfg.Pipeline(filter, b.now, 0, int64(k)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var ids []string
+	for cursor.Next(ctx) {
+		var document models.SearchIndex
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		ids = append(ids, document.ContentID)
+	}
+	return ids, cursor.Err()
+}
+
+// Close disconnects from MongoDB
+func (b *mongoBackend) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b.client.Disconnect(ctx)
+}
+
+// memoryBackend ranks documents loaded from an NDJSON fixture
+type memoryBackend struct {
+	docs []models.SearchIndex
+	now  time.Time
+}
+
+// newMemoryBackend loads one models.SearchIndex JSON document per line
+func newMemoryBackend(path string, now time.Time) (*memoryBackend, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	backend := &memoryBackend{now: now}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var doc models.SearchIndex
+		if err := json.Unmarshal([]byte(text), &doc); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		backend.docs = append(backend.docs, doc)
+	}
+	return backend, scanner.Err()
+}
+
+// Search ranks the fixture with the config's field weights and boosts
+func (b *memoryBackend) Search(_ context.Context, cfg ranking.Config, query, contentType string, k int) ([]string, error) {
+	ranked := cfg.Rank(b.docs, query, contentType, b.now)
+	if len(ranked) > k {
+		ranked = ranked[:k]
+	}
+
+	ids := make([]string, len(ranked))
+	for i, scored := range ranked {
+		ids[i] = scored.Document.ContentID
+	}
+	return ids, nil
+}
+
+// Close is a no-op for fixtures
+func (b *memoryBackend) Close() {}
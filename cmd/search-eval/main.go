@@ -0,0 +1,243 @@
+// Command search-eval measures search relevance offline against a judgment set.
+//
+// Judgments are NDJSON, one query per line:
+//
+//	{"query": "remote work", "type": "post", "relevant": {"post-17": 3, "post-4": 1}}
+//
+// Each query runs against MongoDB or an in-memory NDJSON fixture of search_index
+// documents and is scored with NDCG@k, MRR, precision@k and recall@k. Passing
+// -config-b compares two ranking configurations query by query.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"circleconnect-search/ranking"
+)
+
+// QueryReport holds the results of one judged query for each configuration
+type QueryReport struct {
+	Query   string   `json:"query"`
+	A       Metrics  `json:"a"`
+	B       *Metrics `json:"b,omitempty"`
+	Delta   *Metrics `json:"delta,omitempty"`
+	RankedA []string `json:"ranked_a"`
+	RankedB []string `json:"ranked_b,omitempty"`
+}
+
+// Report is the full evaluation output
+type Report struct {
+	Backend string          `json:"backend"`
+	K       int             `json:"k"`
+	ConfigA ranking.Config  `json:"config_a"`
+	ConfigB *ranking.Config `json:"config_b,omitempty"`
+	MeanA   Metrics         `json:"mean_a"`
+	MeanB   *Metrics        `json:"mean_b,omitempty"`
+	Delta   *Metrics        `json:"delta,omitempty"`
+	Queries []QueryReport   `json:"queries"`
+}
+
+func main() {
+	judgmentsPath := flag.String("judgments", "", "NDJSON judgment file (required)")
+	backendName := flag.String("backend", "memory", "backend to query: memory or mongo")
+	fixturePath := flag.String("fixture", "", "NDJSON fixture of search_index documents (memory backend)")
+	mongoURI := flag.String("mongo-uri", getEnv("MONGO_URI", "mongodb://localhost:27017"), "MongoDB connection string (mongo backend)")
+	mongoDB := flag.String("mongo-db", getEnv("MONGO_DB", "circleconnect_search"), "MongoDB database name (mongo backend)")
+	configA := flag.String("config-a", "", "ranking config JSON for the baseline (default ranking if empty)")
+	configB := flag.String("config-b", "", "ranking config JSON to compare against the baseline")
+	k := flag.Int("k", 10, "rank cutoff for all metrics")
+	asOf := flag.String("as-of", "", "RFC3339 time used for recency boosts (default now)")
+	jsonOutput := flag.Bool("json", false, "write the report as JSON")
+	flag.Parse()
+
+	if *judgmentsPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *k < 1 {
+		log.Fatal("-k must be at least 1")
+	}
+
+	judgments, err := loadJudgments(*judgmentsPath)
+	if err != nil {
+		log.Fatalf("Loading judgments: %v", err)
+	}
+
+	now := time.Now()
+	if *asOf != "" {
+		now, err = time.Parse(time.RFC3339, *asOf)
+		if err != nil {
+			log.Fatalf("Invalid -as-of: %v", err)
+		}
+	}
+
+	var backend Backend
+	switch *backendName {
+	case "memory":
+		if *fixturePath == "" {
+			log.Fatal("-fixture is required for the memory backend")
+		}
+		backend, err = newMemoryBackend(*fixturePath, now)
+	case "mongo":
+		backend, err = newMongoBackend(*mongoURI, *mongoDB, now)
+	default:
+		log.Fatalf("Unknown backend %q", *backendName)
+	}
+	if err != nil {
+		log.Fatalf("Opening %s backend: %v", *backendName, err)
+	}
+	defer backend.Close()
+
+	cfgA, err := ranking.LoadConfig(*configA)
+	if err != nil {
+		log.Fatalf("Loading config A: %v", err)
+	}
+
+	report := Report{Backend: *backendName, K: *k, ConfigA: cfgA}
+	var cfgB *ranking.Config
+	if *configB != "" {
+		loaded, err := ranking.LoadConfig(*configB)
+		if err != nil {
+			log.Fatalf("Loading config B: %v", err)
+		}
+		cfgB = &loaded
+		report.ConfigB = cfgB
+	}
+
+	if *backendName == "mongo" {
+		warnFixedWeights(cfgA)
+		if cfgB != nil {
+			warnFixedWeights(*cfgB)
+		}
+	}
+
+	ctx := context.Background()
+	var allA, allB []Metrics
+	for _, judgment := range judgments {
+		rankedA, err := backend.Search(ctx, cfgA, judgment.Query, judgment.ContentType, *k)
+		if err != nil {
+			log.Fatalf("Query %q: %v", judgment.Query, err)
+		}
+
+		q := QueryReport{Query: judgment.Query, A: evaluate(rankedA, judgment, *k), RankedA: rankedA}
+		allA = append(allA, q.A)
+
+		if cfgB != nil {
+			rankedB, err := backend.Search(ctx, *cfgB, judgment.Query, judgment.ContentType, *k)
+			if err != nil {
+				log.Fatalf("Query %q: %v", judgment.Query, err)
+			}
+			metricsB := evaluate(rankedB, judgment, *k)
+			delta := sub(metricsB, q.A)
+			q.B, q.Delta, q.RankedB = &metricsB, &delta, rankedB
+			allB = append(allB, metricsB)
+		}
+
+		report.Queries = append(report.Queries, q)
+	}
+
+	report.MeanA = mean(allA)
+	if cfgB != nil {
+		meanB := mean(allB)
+		delta := sub(meanB, report.MeanA)
+		report.MeanB, report.Delta = &meanB, &delta
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printReport(report)
+}
+
+// loadJudgments reads one Judgment per line, skipping blanks and # comments
+func loadJudgments(path string) ([]Judgment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var judgments []Judgment
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var judgment Judgment
+		if err := json.Unmarshal([]byte(text), &judgment); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if judgment.Query == "" {
+			return nil, fmt.Errorf("%s:%d: query is required", path, line)
+		}
+		judgments = append(judgments, judgment)
+	}
+	return judgments, scanner.Err()
+}
+
+// warnFixedWeights flags configs whose field weights can't take effect in Mongo
+func warnFixedWeights(cfg ranking.Config) {
+	for field, weight := range ranking.IndexFieldWeights() {
+		if cfg.FieldWeights[field] != weight {
+			log.Printf("Warning: config %q changes the %s weight, but Mongo uses the weights baked into text_search_index; use -backend memory to evaluate field weights", cfg.Name, field)
+			return
+		}
+	}
+}
+
+// printReport writes a human-readable summary and, for comparisons, the changed queries
+func printReport(report Report) {
+	fmt.Printf("Backend: %s, queries: %d, k: %d\n\n", report.Backend, len(report.Queries), report.K)
+	fmt.Printf("%-24s %8s %8s %8s %8s\n", "config", "ndcg", "mrr", "prec", "recall")
+	printMetrics(report.ConfigA.Name, report.MeanA)
+	if report.ConfigB == nil {
+		return
+	}
+	printMetrics(report.ConfigB.Name, *report.MeanB)
+	printMetrics("delta", *report.Delta)
+
+	fmt.Println("\nQueries with a changed NDCG:")
+	changed := 0
+	for _, q := range report.Queries {
+		if math.Abs(q.Delta.NDCG) < 1e-9 {
+			continue
+		}
+		changed++
+		fmt.Printf("  %+.4f  %-40q a=%v b=%v\n", q.Delta.NDCG, q.Query, q.RankedA, q.RankedB)
+	}
+	if changed == 0 {
+		fmt.Println("  none")
+	}
+}
+
+// printMetrics writes one summary row
+func printMetrics(name string, m Metrics) {
+	fmt.Printf("%-24s %8.4f %8.4f %8.4f %8.4f\n", name, m.NDCG, m.MRR, m.Precision, m.Recall)
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
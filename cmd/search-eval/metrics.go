@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Judgment grades the relevant content IDs for one query. Grades are 0 (not
+// relevant) upwards; anything missing from Relevant counts as 0.
+type Judgment struct {
+	Query       string         `json:"query"`
+	ContentType string         `json:"type,omitempty"`
+	Relevant    map[string]int `json:"relevant"`
+}
+
+// Metrics holds the scores for one query or the mean over a judgment set
+type Metrics struct {
+	NDCG      float64 `json:"ndcg"`
+	MRR       float64 `json:"mrr"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+}
+
+// evaluate scores a ranked list of content IDs against a judgment at cutoff k
+func evaluate(ranked []string, judgment Judgment, k int) Metrics {
+	if len(ranked) > k {
+		ranked = ranked[:k]
+	}
+
+	relevantTotal := 0
+	for _, grade := range judgment.Relevant {
+		if grade > 0 {
+			relevantTotal++
+		}
+	}
+
+	var m Metrics
+	dcg := 0.0
+	hits := 0
+	for i, id := range ranked {
+		grade := judgment.Relevant[id]
+		if grade <= 0 {
+			continue
+		}
+		hits++
+		dcg += gain(grade) / math.Log2(float64(i+2))
+		if m.MRR == 0 {
+			m.MRR = 1 / float64(i+1)
+		}
+	}
+
+	if idcg := idealDCG(judgment, k); idcg > 0 {
+		m.NDCG = dcg / idcg
+	}
+	m.Precision = float64(hits) / float64(k)
+	if relevantTotal > 0 {
+		m.Recall = float64(hits) / float64(relevantTotal)
+	}
+	return m
+}
+
+// gain is the exponential gain used by NDCG so highly relevant hits dominate
+func gain(grade int) float64 {
+	return math.Pow(2, float64(grade)) - 1
+}
+
+// idealDCG is the DCG of the best possible ordering of the judged documents
+func idealDCG(judgment Judgment, k int) float64 {
+	grades := make([]int, 0, len(judgment.Relevant))
+	for _, grade := range judgment.Relevant {
+		if grade > 0 {
+			grades = append(grades, grade)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(grades)))
+
+	dcg := 0.0
+	for i, grade := range grades {
+		if i >= k {
+			break
+		}
+		dcg += gain(grade) / math.Log2(float64(i+2))
+	}
+	return dcg
+}
+
+// mean averages per-query metrics
+func mean(all []Metrics) Metrics {
+	var m Metrics
+	if len(all) == 0 {
+		return m
+	}
+	for _, q := range all {
+		m.NDCG += q.NDCG
+		m.MRR += q.MRR
+		m.Precision += q.Precision
+		m.Recall += q.Recall
+	}
+	n := float64(len(all))
+	return Metrics{NDCG: m.NDCG / n, MRR: m.MRR / n, Precision: m.Precision / n, Recall: m.Recall / n}
+}
+
+// sub returns the per-metric difference b - a
+func sub(b, a Metrics) Metrics {
+	return Metrics{
+		NDCG:      b.NDCG - a.NDCG,
+		MRR:       b.MRR - a.MRR,
+		Precision: b.Precision - a.Precision,
+		Recall:    b.Recall - a.Recall,
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+const tolerance = 1e-9
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		ranked   []string
+		relevant map[string]int
+		k        int
+		want     Metrics
+	}{
+		{
+			name:     "ideal order",
+			ranked:   []string{"a", "b"},
+			relevant: map[string]int{"a": 2, "b": 1},
+			k:        2,
+			want:     Metrics{NDCG: 1, MRR: 1, Precision: 1, Recall: 1},
+		},
+		{
+			// DCG 1/log2(2) + 3/log2(3) over the ideal 3/log2(2) + 1/log2(3)
+			name:     "reversed order",
+			ranked:   []string{"b", "a"},
+			relevant: map[string]int{"a": 2, "b": 1},
+			k:        2,
+			want:     Metrics{NDCG: 0.7967075809905066, MRR: 1, Precision: 1, Recall: 1},
+		},
+		{
+			// DCG 1/log2(4) over the ideal 1/log2(2)
+			name:     "first hit at rank three",
+			ranked:   []string{"x", "y", "a"},
+			relevant: map[string]int{"a": 1},
+			k:        3,
+			want:     Metrics{NDCG: 0.5, MRR: 1.0 / 3, Precision: 1.0 / 3, Recall: 1},
+		},
+		{
+			name:     "cutoff drops the hit",
+			ranked:   []string{"x", "a"},
+			relevant: map[string]int{"a": 1},
+			k:        1,
+			want:     Metrics{},
+		},
+		{
+			// Precision still divides by k; the ideal ranking has both documents,
+			// so DCG 1 is over 1/log2(2) + 1/log2(3)
+			name:     "k larger than the result count",
+			ranked:   []string{"a"},
+			relevant: map[string]int{"a": 1, "b": 1},
+			k:        5,
+			want:     Metrics{NDCG: 0.6131471927654584, MRR: 1, Precision: 0.2, Recall: 0.5},
+		},
+		{
+			name:     "no results",
+			ranked:   nil,
+			relevant: map[string]int{"a": 1},
+			k:        10,
+			want:     Metrics{},
+		},
+		{
+			name:     "only grade zero judgments",
+			ranked:   []string{"a", "b"},
+			relevant: map[string]int{"a": 0, "b": 0},
+			k:        2,
+			want:     Metrics{},
+		},
+		{
+			name:     "no judgments",
+			ranked:   []string{"a", "b"},
+			relevant: nil,
+			k:        2,
+			want:     Metrics{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evaluate(tt.ranked, Judgment{Query: "q", Relevant: tt.relevant}, tt.k)
+			if !closeMetrics(got, tt.want) {
+				t.Errorf("evaluate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdealDCG(t *testing.T) {
+	relevant := map[string]int{"a": 3, "b": 0, "c": 1, "d": 2}
+	tests := []struct {
+		name     string
+		relevant map[string]int
+		k        int
+		want     float64
+	}{
+		{"cutoff keeps the best grades", relevant, 2, 7 + 3/math.Log2(3)},
+		{"k larger than the judged documents", relevant, 10, 7 + 3/math.Log2(3) + 1.0/2},
+		{"no relevant documents", map[string]int{"a": 0}, 5, 0},
+		{"no judgments", nil, 5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idealDCG(Judgment{Relevant: tt.relevant}, tt.k); math.Abs(got-tt.want) > tolerance {
+				t.Errorf("idealDCG() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func closeMetrics(a, b Metrics) bool {
+	return math.Abs(a.NDCG-b.NDCG) <= tolerance &&
+		math.Abs(a.MRR-b.MRR) <= tolerance &&
+		math.Abs(a.Precision-b.Precision) <= tolerance &&
+		math.Abs(a.Recall-b.Recall) <= tolerance
+}
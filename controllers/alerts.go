@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/database"
+	"circleconnect-search/middleware"
+	"circleconnect-search/models"
+	"circleconnect-search/ranking"
+)
+
+// Saved search alert tuning
+const (
+	defaultAlertStream          = "search:alerts"
+	alertStreamMaxLen           = 10000
+	defaultAlertThrottleMinutes = 60
+	maxAlertThrottleMinutes     = 7 * 24 * 60
+	maxAlertCandidates          = 1000 // Subscriptions checked per indexed document
+)
+
+// alertPhrasePattern finds quoted phrases in a saved query
+var alertPhrasePattern = regexp.MustCompile(`"([^"]+)"`)
+
+// alertHTTPClient delivers alerts to the webhook
+var alertHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+// alertTerm analyzes a query or document word so both sides compare equal
+func alertTerm(word string) string {
+	return ranking.Stem(analysis.Analyze(word))
+}
+
+// alertTerms returns the terms a document must share with a saved query to be a
+// candidate match: analyzed positive words, #hashtags and @mentions
+func alertTerms(query string) []string {
+	parsed := analysis.ParseEntityQuery(query)
+	var terms []string
+	for _, word := range ranking.QueryTerms(parsed.Text) {
+		if !analysis.IsStopword(word) {
+			terms = analysis.MergeUnique(terms, alertTerm(word))
+		}
+	}
+	for _, hashtag := range parsed.Hashtags {
+		terms = analysis.MergeUnique(terms, "#"+hashtag)
+	}
+	for _, mention := range parsed.Mentions {
+		terms = analysis.MergeUnique(terms, "@"+mention)
+	}
+	return terms
+}
+
+// documentTerms returns the analyzed words, #hashtags and @mentions of a document.
+// The author counts as a mention, as it does in Search.
+func documentTerms(doc models.SearchIndex) map[string]bool {
+	terms := make(map[string]bool)
+	for _, word := range ranking.Tokenize(documentText(doc)) {
+		terms[alertTerm(word)] = true
+	}
+	for _, hashtag := range doc.Hashtags {
+		terms["#"+hashtag] = true
+	}
+	for _, mention := range doc.Mentions {
+		terms["@"+mention] = true
+	}
+	if doc.Author != "" {
+		terms["@"+strings.ToLower(doc.Author)] = true
+	}
+	return terms
+}
+
+// documentText joins the searchable text fields of a document
+func documentText(doc models.SearchIndex) string {
+	return strings.Join(append([]string{doc.Title, doc.Content}, doc.Tags...), "\n")
+}
+
+// matchesSavedSearch applies a saved query to a single document with Search's
+// semantics: every #hashtag and @mention is required, any one word matches,
+// quoted phrases must appear and negated words must not
+func matchesSavedSearch(saved models.SavedSearch, doc models.SearchIndex, terms map[string]bool) bool {
+	if contentType := saved.Filters["type"]; contentType != "" && contentType != string(doc.ContentType) {
+		return false
+	}
+	if lang := saved.Filters["lang"]; lang != "" && lang != doc.Lang {
+		return false
+	}
+
+	parsed := analysis.ParseEntityQuery(saved.Query)
+	for _, hashtag := range parsed.Hashtags {
+		if !terms["#"+hashtag] {
+			return false
+		}
+	}
+	for _, mention := range parsed.Mentions {
+		if !terms["@"+mention] {
+			return false
+		}
+	}
+
+	for _, field := range strings.Fields(parsed.Text) {
+		if !strings.HasPrefix(field, "-") {
+			continue
+		}
+		for _, word := range ranking.Tokenize(field) {
+			if terms[alertTerm(word)] {
+				return false
+			}
+		}
+	}
+
+	text := strings.Join(ranking.Tokenize(analysis.Analyze(documentText(doc))), " ")
+	for _, match := range alertPhrasePattern.FindAllStringSubmatch(parsed.Text, -1) {
+		phrase := strings.Join(ranking.Tokenize(analysis.Analyze(match[1])), " ")
+		if phrase != "" && !strings.Contains(" "+text+" ", " "+phrase+" ") {
+			return false
+		}
+	}
+
+	words := ranking.QueryTerms(parsed.Text)
+	if len(words) == 0 {
+		return true
+	}
+	for _, word := range words {
+		if !analysis.IsStopword(word) && terms[alertTerm(word)] {
+			return true
+		}
+	}
+	return false
+}
+
+// percolateAlertsAsync matches a newly indexed document against the active saved
+// search subscriptions and notifies their owners in the background
+func percolateAlertsAsync(doc models.SearchIndex) {
+	if database.MongoDB == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		terms := documentTerms(doc)
+		candidateTerms := make([]string, 0, len(terms))
+		for term := range terms {
+			candidateTerms = append(candidateTerms, term)
+		}
+
+		// Only subscriptions sharing a term with the document can match
+		cursor, err := database.MongoDB.Collection("saved_searches").Find(ctx, bson.M{
+			"alert.enabled": true,
+			"alert_terms":   bson.M{"$in": candidateTerms},
+			"user_id":       bson.M{"$ne": doc.Author},
+		}, options.Find().SetLimit(maxAlertCandidates))
+		if err != nil {
+			log.Printf("Error finding alert subscriptions: %v", err)
+			return
+		}
+		var candidates []models.SavedSearch
+		if err := cursor.All(ctx, &candidates); err != nil {
+			log.Printf("Error processing alert subscriptions: %v", err)
+			return
+		}
+
+		// Resolve the access of each subscriber once, however many of their
+		// saved searches match
+		canView := map[string]bool{}
+		for _, saved := range candidates {
+			if !matchesSavedSearch(saved, doc, terms) {
+				continue
+			}
+			allowed, ok := canView[saved.UserID]
+			if !ok {
+				allowed = viewerFor(ctx, saved.UserID).CanView(doc)
+				canView[saved.UserID] = allowed
+			}
+			if !allowed {
+				continue
+			}
+
+			now := time.Now()
+			claimed, err := claimAlert(ctx, saved, now)
+			if err != nil {
+				log.Printf("Error throttling alert %s: %v", saved.ID.Hex(), err)
+				continue
+			}
+			if !claimed {
+				continue
+			}
+
+			event := models.AlertEvent{
+				Type:            "saved_search_alert",
+				UserID:          saved.UserID,
+				SavedSearchID:   saved.ID.Hex(),
+				SavedSearchName: saved.Name,
+				Query:           saved.Query,
+				ContentID:       doc.ContentID,
+				ContentType:     doc.ContentType,
+				Title:           doc.Title,
+				Author:          doc.Author,
+				MatchedAt:       now,
+			}
+			if err := publishAlert(ctx, event); err != nil {
+				log.Printf("Error publishing alert %s: %v", saved.ID.Hex(), err)
+			}
+		}
+	}()
+}
+
+// claimAlert records that a subscription is notified now, unless it was already
+// notified within its throttle interval. The conditional update makes the check
+// atomic across service instances.
+func claimAlert(ctx context.Context, saved models.SavedSearch, now time.Time) (bool, error) {
+	throttle := time.Duration(saved.Alert.ThrottleMinutes) * time.Minute
+	result, err := database.MongoDB.Collection("saved_searches").UpdateOne(ctx,
+		bson.M{
+			"_id":           saved.ID,
+			"alert.enabled": true,
+			"$or": []bson.M{
+				{"alert.last_sent_at": bson.M{"$exists": false}},
+				{"alert.last_sent_at": bson.M{"$lte": now.Add(-throttle)}},
+			},
+		},
+		bson.M{"$set": bson.M{"alert.last_sent_at": now}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// publishAlert posts the event to ALERT_WEBHOOK_URL when configured, otherwise it
+// appends it to the Redis stream the notification service consumes
+func publishAlert(ctx context.Context, event models.AlertEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if webhook := os.Getenv("ALERT_WEBHOOK_URL"); webhook != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey := os.Getenv("SERVICE_API_KEY"); apiKey != "" {
+			req.Header.Set("X-Service-API-Key", apiKey)
+		}
+
+		resp, err := alertHTTPClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	if RedisClient == nil {
+		return fmt.Errorf("no alert destination configured")
+	}
+	stream := os.Getenv("ALERT_STREAM")
+	if stream == "" {
+		stream = defaultAlertStream
+	}
+	return RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: alertStreamMaxLen,
+		Approx: true,
+		Values: map[string]any{"user_id": event.UserID, "event": string(payload)},
+	}).Err()
+}
+
+// EnableSearchAlert subscribes one of the authenticated user's saved searches to
+// newly indexed matching content
+func (sc *SearchController) EnableSearchAlert(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return
+	}
+
+	var request struct {
+		ThrottleMinutes int `json:"throttle_minutes" binding:"omitempty,min=1"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if request.ThrottleMinutes == 0 {
+		request.ThrottleMinutes = defaultAlertThrottleMinutes
+	}
+	if request.ThrottleMinutes > maxAlertThrottleMinutes {
+		request.ThrottleMinutes = maxAlertThrottleMinutes
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := database.MongoDB.Collection("saved_searches")
+	var saved models.SavedSearch
+	if err := collection.FindOne(ctx, bson.M{"_id": id, "user_id": user.ID}).Decode(&saved); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+
+	terms := alertTerms(saved.Query)
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Saved search has no terms to alert on"})
+		return
+	}
+
+	saved.Alert = &models.SearchAlert{Enabled: true, ThrottleMinutes: request.ThrottleMinutes}
+	saved.AlertTerms = terms
+	saved.UpdatedAt = time.Now()
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": id, "user_id": user.ID}, bson.M{
+		"$set": bson.M{
+			"alert.enabled":          true,
+			"alert.throttle_minutes": request.ThrottleMinutes,
+			"alert_terms":            terms,
+			"updated_at":             saved.UpdatedAt,
+		},
+	})
+	if err != nil {
+		log.Printf("Enable search alert error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable alert"})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// DisableSearchAlert unsubscribes one of the authenticated user's saved searches
+func (sc *SearchController) DisableSearchAlert(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := database.MongoDB.Collection("saved_searches").UpdateOne(ctx,
+		bson.M{"_id": id, "user_id": user.ID},
+		bson.M{
+			"$set":   bson.M{"updated_at": time.Now()},
+			"$unset": bson.M{"alert": "", "alert_terms": ""},
+		},
+	)
+	if err != nil {
+		log.Printf("Disable search alert error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable alert"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert disabled successfully"})
+}
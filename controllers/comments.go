@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/database"
+	"circleconnect-search/models"
+)
+
+// defaultPostURLTemplate links comment results to their post; {id} is replaced
+// with the post's content ID
+const defaultPostURLTemplate = "/posts/{id}"
+
+// postURL returns the link to a post, using POST_URL_TEMPLATE when set
+func postURL(postID string) string {
+	template := os.Getenv("POST_URL_TEMPLATE")
+	if template == "" {
+		template = defaultPostURLTemplate
+	}
+	return strings.ReplaceAll(template, "{id}", postID)
+}
+
+// parentRef returns the parent post reference of a comment document
+func parentRef(document models.SearchIndex) *models.ParentRef {
+	if document.ContentType != models.Comment || document.ParentPostID == "" {
+		return nil
+	}
+	return &models.ParentRef{
+		ContentID:   document.ParentPostID,
+		CommunityID: document.CommunityID,
+		URL:         postURL(document.ParentPostID),
+	}
+}
+
+// threadKey identifies the thread a document belongs to for collapse=post:
+// comments share their post's key, anything else is its own thread
+func threadKey(document models.SearchIndex) string {
+	if document.ContentType == models.Comment && document.ParentPostID != "" {
+		return "post:" + document.ParentPostID
+	}
+	return document.ID.Hex()
+}
+
+// collapseThreads keeps the best ranked document of each thread, in order, and
+// records how many documents of the ranked list it stands for
+func collapseThreads(documents []rankedDocument) []rankedDocument {
+	positions := map[string]int{}
+	var collapsed []rankedDocument
+	for _, document := range documents {
+		key := threadKey(document.SearchIndex)
+		if position, ok := positions[key]; ok {
+			collapsed[position].ThreadHits++
+			continue
+		}
+		positions[key] = len(collapsed)
+		document.ThreadHits = 1
+		collapsed = append(collapsed, document)
+	}
+	return collapsed
+}
+
+// attachParents fills in the titles of the posts that comment results belong
+// to, for the posts the viewer may see
+func attachParents(ctx context.Context, viewer searchViewer, results []models.SearchResult) {
+	var postIDs []string
+	for _, result := range results {
+		if result.Parent != nil {
+			postIDs = append(postIDs, result.Parent.ContentID)
+		}
+	}
+	postIDs = uniqueIDs(postIDs)
+	if len(postIDs) == 0 {
+		return
+	}
+
+	filter := bson.M{"content_type": models.Post, "content_id": bson.M{"$in": postIDs}}
+	andFilter(filter, viewer.Filter())
+	andFilter(filter, visibleFilter())
+
+	cursor, err := database.MongoDB.Collection("search_index").Find(ctx, filter,
+		options.Find().SetProjection(bson.M{"content_id": 1, "title": 1, "community_id": 1}))
+	if err != nil {
+		log.Printf("Error loading parent posts: %v", err)
+		return
+	}
+	var posts []models.SearchIndex
+	if err := cursor.All(ctx, &posts); err != nil {
+		log.Printf("Error decoding parent posts: %v", err)
+		return
+	}
+
+	byID := make(map[string]models.SearchIndex, len(posts))
+	for _, post := range posts {
+		byID[post.ContentID] = post
+	}
+	for _, result := range results {
+		if result.Parent == nil {
+			continue
+		}
+		if post, ok := byID[result.Parent.ContentID]; ok {
+			result.Parent.Title = analysis.RedactEmails(post.Title)
+			if result.Parent.CommunityID == "" {
+				result.Parent.CommunityID = post.CommunityID
+			}
+		}
+	}
+}
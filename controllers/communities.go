@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/models"
+)
+
+// prepareCommunityDocument fills the structured metrics of a community document.
+// Metrics still sent in metadata by older indexers are moved to the community
+// fields unless those are supplied.
+func prepareCommunityDocument(doc *models.SearchIndex) {
+	if doc.Community == nil {
+		doc.Community = &models.CommunityInfo{}
+		if value, ok := metadataNumber(doc.Metadata, "member_count"); ok {
+			doc.Community.MemberCount = value
+		}
+		if value, ok := metadataNumber(doc.Metadata, "posts_last_7d"); ok {
+			doc.Community.PostsLast7d = value
+		}
+		if value, ok := doc.Metadata["category"].(string); ok {
+			doc.Community.Category = value
+		}
+		if value, ok := doc.Metadata["location"].(string); ok {
+			doc.Community.Location = value
+		}
+		if value, ok := doc.Metadata["joinable"].(bool); ok {
+			doc.Community.Joinable = value
+		}
+	}
+	for _, key := range []string{"member_count", "posts_last_7d", "category", "location", "joinable"} {
+		delete(doc.Metadata, key)
+	}
+
+	doc.Community.Category = strings.ToLower(strings.TrimSpace(doc.Community.Category))
+	doc.Community.Location = strings.TrimSpace(doc.Community.Location)
+}
+
+// metadataNumber reads a whole number from metadata decoded from JSON
+func metadataNumber(metadata map[string]any, key string) (int, bool) {
+	switch value := metadata[key].(type) {
+	case float64:
+		return int(value), true
+	case int:
+		return value, true
+	case int64:
+		return int(value), true
+	}
+	return 0, false
+}
+
+// communityFilters holds the community-specific search parameters
+type communityFilters struct {
+	Categories []string
+	MinMembers int
+	Joinable   bool
+}
+
+// parseCommunityFilters reads the category, min_members and joinable parameters
+func parseCommunityFilters(c *gin.Context) (communityFilters, error) {
+	var filters communityFilters
+	for _, category := range strings.Split(c.Query("category"), ",") {
+		if category = strings.ToLower(strings.TrimSpace(category)); category != "" {
+			filters.Categories = analysis.MergeUnique(filters.Categories, category)
+		}
+	}
+	sort.Strings(filters.Categories)
+
+	if minMembers := c.Query("min_members"); minMembers != "" {
+		value, err := strconv.Atoi(minMembers)
+		if err != nil || value < 0 {
+			return filters, fmt.Errorf("invalid min_members, expected a non-negative number")
+		}
+		filters.MinMembers = value
+	}
+
+	filters.Joinable = c.Query("joinable") == "true"
+	return filters, nil
+}
+
+// IsSet reports whether any community filter was requested
+func (f communityFilters) IsSet() bool {
+	return len(f.Categories) > 0 || f.MinMembers > 0 || f.Joinable
+}
+
+// CacheKey identifies the filters in result cache keys
+func (f communityFilters) CacheKey() string {
+	return fmt.Sprintf("%s:%d:%t", strings.Join(f.Categories, ","), f.MinMembers, f.Joinable)
+}
+
+// Apply restricts the filter to communities matching the community filters
+func (f communityFilters) Apply(filter bson.M) {
+	if !f.IsSet() {
+		return
+	}
+	filter["content_type"] = models.Community
+	if len(f.Categories) > 0 {
+		filter["community.category"] = bson.M{"$in": f.Categories}
+	}
+	if f.MinMembers > 0 {
+		filter["community.member_count"] = bson.M{"$gte": f.MinMembers}
+	}
+	if f.Joinable {
+		filter["community.joinable"] = true
+	}
+}
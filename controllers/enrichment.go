@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/database"
+	"circleconnect-search/models"
+)
+
+// Enrichment tuning
+const (
+	usersTable         = "users"
+	communitiesTable   = "communities"
+	enrichmentCacheTTL = time.Minute
+)
+
+// Fields that can be enriched, selected with ENRICH_FIELDS
+const (
+	enrichAuthorName    = "author_name"
+	enrichAuthorAvatar  = "author_avatar"
+	enrichCommunityName = "community_name"
+)
+
+// EnrichFields are the result fields filled in from Postgres, set at startup
+// from ENRICH_FIELDS
+var EnrichFields = map[string]bool{enrichAuthorName: true, enrichAuthorAvatar: true, enrichCommunityName: true}
+
+// EnrichFieldsFromEnv reads the comma-separated ENRICH_FIELDS, keeping every
+// field when it is unset; "none" turns enrichment off
+func EnrichFieldsFromEnv() map[string]bool {
+	value, ok := os.LookupEnv("ENRICH_FIELDS")
+	if !ok {
+		return map[string]bool{enrichAuthorName: true, enrichAuthorAvatar: true, enrichCommunityName: true}
+	}
+	fields := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "", "none":
+		case enrichAuthorName, enrichAuthorAvatar, enrichCommunityName:
+			fields[name] = true
+		default:
+			log.Printf("Warning: Unknown ENRICH_FIELDS entry %q", name)
+		}
+	}
+	return fields
+}
+
+// userProfile is the part of a user's Postgres profile shown with results
+type userProfile struct {
+	ID          string `gorm:"column:id" json:"id"`
+	Username    string `gorm:"column:username" json:"username"`
+	DisplayName string `gorm:"column:display_name" json:"display_name"`
+	AvatarURL   string `gorm:"column:avatar_url" json:"avatar_url"`
+}
+
+// communityName is a community's current name in Postgres
+type communityName struct {
+	ID   string `gorm:"column:id" json:"id"`
+	Name string `gorm:"column:name" json:"name"`
+}
+
+// enrichResults fills in the current author profiles and community names of
+// results with one batched lookup each, so clients don't have to fetch them per
+// result. Lookup failures leave the results as they are. Results are cached
+// before they are enriched, so the details are as fresh as enrichmentCacheTTL.
+func enrichResults(ctx context.Context, results []models.SearchResult) {
+	fields := EnrichFields
+	if len(fields) == 0 || len(results) == 0 {
+		return
+	}
+
+	var authorIDs, communityIDs []string
+	for _, result := range results {
+		if (fields[enrichAuthorName] || fields[enrichAuthorAvatar]) && result.Author != "" {
+			authorIDs = append(authorIDs, result.Author)
+		}
+		if fields[enrichCommunityName] {
+			if id := resultCommunityID(result); id != "" {
+				communityIDs = append(communityIDs, id)
+			}
+		}
+	}
+	authorIDs, communityIDs = uniqueIDs(authorIDs), uniqueIDs(communityIDs)
+
+	profiles, err := userProfiles(ctx, authorIDs)
+	if err != nil {
+		log.Printf("Error loading author profiles: %v", err)
+	}
+	names, err := communityNames(ctx, communityIDs)
+	if err != nil {
+		log.Printf("Error loading community names: %v", err)
+	}
+
+	for i := range results {
+		result := &results[i]
+		if profile, ok := profiles[result.Author]; ok && profile.ID != "" {
+			author := &models.AuthorProfile{}
+			if fields[enrichAuthorName] {
+				author.Username = profile.Username
+				author.DisplayName = analysis.RedactEmails(profile.DisplayName)
+			}
+			if fields[enrichAuthorAvatar] {
+				author.AvatarURL = profile.AvatarURL
+			}
+			result.AuthorProfile = author
+		}
+		if community, ok := names[resultCommunityID(*result)]; ok && community.ID != "" {
+			result.CommunityName = community.Name
+		}
+	}
+}
+
+// enrichGroups enriches the results of every group with one batch of lookups
+func enrichGroups(ctx context.Context, groups []searchGroup) {
+	var results []models.SearchResult
+	for _, group := range groups {
+		results = append(results, group.Results...)
+	}
+	enrichResults(ctx, results)
+	for i := range groups {
+		n := copy(groups[i].Results, results)
+		results = results[n:]
+	}
+}
+
+// enrichCachedResponse enriches the results or groups of a cached response,
+// which come back from Redis as generic JSON values
+func enrichCachedResponse(ctx context.Context, cachedResults gin.H) {
+	if len(EnrichFields) == 0 {
+		return
+	}
+	if value, ok := cachedResults["results"]; ok {
+		var results []models.SearchResult
+		if err := decodeCachedValue(value, &results); err != nil {
+			log.Printf("Error decoding cached results for enrichment: %v", err)
+			return
+		}
+		enrichResults(ctx, results)
+		cachedResults["results"] = results
+	}
+	if value, ok := cachedResults["groups"]; ok {
+		var groups []searchGroup
+		if err := decodeCachedValue(value, &groups); err != nil {
+			log.Printf("Error decoding cached groups for enrichment: %v", err)
+			return
+		}
+		enrichGroups(ctx, groups)
+		cachedResults["groups"] = groups
+	}
+}
+
+// decodeCachedValue converts a value decoded from cached JSON into a typed value
+func decodeCachedValue(value any, target any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// resultCommunityID returns the community a result belongs to, or the community
+// itself for community results
+func resultCommunityID(result models.SearchResult) string {
+	if result.ContentType == models.Community {
+		return result.ContentID
+	}
+	if result.CommunityID != "" {
+		return result.CommunityID
+	}
+	if result.Parent != nil {
+		return result.Parent.CommunityID
+	}
+	return ""
+}
+
+// userProfiles returns the profiles of users by ID, read from Postgres and
+// cached in Redis briefly. IDs without a profile map to a zero profile.
+func userProfiles(ctx context.Context, userIDs []string) (map[string]userProfile, error) {
+	profiles := map[string]userProfile{}
+	missing := cachedEnrichment(ctx, "enrich:user:", userIDs, profiles)
+	if len(missing) == 0 {
+		return profiles, nil
+	}
+
+	if database.PgDB == nil {
+		return profiles, fmt.Errorf("postgres not connected")
+	}
+
+	var rows []userProfile
+	err := database.PgDB.WithContext(ctx).
+		Table(usersTable).
+		Select("id, username, display_name, avatar_url").
+		Where("id IN ?", missing).
+		Scan(&rows).Error
+	if err != nil {
+		return profiles, err
+	}
+
+	loaded := make(map[string]userProfile, len(missing))
+	for _, id := range missing {
+		loaded[id] = userProfile{}
+	}
+	for _, row := range rows {
+		loaded[row.ID] = row
+	}
+	cacheEnrichment(ctx, "enrich:user:", loaded)
+	for id, profile := range loaded {
+		profiles[id] = profile
+	}
+	return profiles, nil
+}
+
+// communityNames returns the names of communities by ID, read from Postgres and
+// cached in Redis briefly. IDs without a community map to a zero value.
+func communityNames(ctx context.Context, communityIDs []string) (map[string]communityName, error) {
+	names := map[string]communityName{}
+	missing := cachedEnrichment(ctx, "enrich:community:", communityIDs, names)
+	if len(missing) == 0 {
+		return names, nil
+	}
+
+	if database.PgDB == nil {
+		return names, fmt.Errorf("postgres not connected")
+	}
+
+	var rows []communityName
+	err := database.PgDB.WithContext(ctx).
+		Table(communitiesTable).
+		Select("id, name").
+		Where("id IN ?", missing).
+		Scan(&rows).Error
+	if err != nil {
+		return names, err
+	}
+
+	loaded := make(map[string]communityName, len(missing))
+	for _, id := range missing {
+		loaded[id] = communityName{}
+	}
+	for _, row := range rows {
+		loaded[row.ID] = row
+	}
+	cacheEnrichment(ctx, "enrich:community:", loaded)
+	for id, name := range loaded {
+		names[id] = name
+	}
+	return names, nil
+}
+
+// cachedEnrichment reads cached entries for the IDs into values and returns the
+// IDs that weren't cached
+func cachedEnrichment[T any](ctx context.Context, prefix string, ids []string, values map[string]T) []string {
+	if RedisClient == nil || len(ids) == 0 {
+		return ids
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = prefix + id
+	}
+	cached, err := RedisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Printf("Error reading cached enrichment: %v", err)
+		return ids
+	}
+
+	var missing []string
+	for i, id := range ids {
+		data, ok := cached[i].(string)
+		var value T
+		if !ok || json.Unmarshal([]byte(data), &value) != nil {
+			missing = append(missing, id)
+			continue
+		}
+		values[id] = value
+	}
+	return missing
+}
+
+// cacheEnrichment caches loaded entries, including misses, for enrichmentCacheTTL
+func cacheEnrichment[T any](ctx context.Context, prefix string, values map[string]T) {
+	if RedisClient == nil {
+		return
+	}
+	pipe := RedisClient.Pipeline()
+	for id, value := range values {
+		if data, err := json.Marshal(value); err == nil {
+			pipe.Set(ctx, prefix+id, data, enrichmentCacheTTL)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Error caching enrichment: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/models"
+)
+
+// Grouped search limits
+const (
+	defaultGroupSize = 5
+	maxGroupSize     = 20
+	groupTimeout     = 3 * time.Second // Per content type, so one slow type can't hold up the others
+)
+
+// groupedTypes are the content types searched by SearchAll, in response order
+var groupedTypes = []models.ContentType{models.Post, models.Community, models.User, models.Comment}
+
+// searchGroup is the top hits of one content type
+type searchGroup struct {
+	Type    models.ContentType    `json:"type"`
+	Results []models.SearchResult `json:"results"`
+	Total   int                   `json:"total"`
+	Error   string                `json:"error,omitempty"`
+	Public  bool                  `json:"-"` // Whether any result is public content
+}
+
+// SearchAll returns the top hits of every content type in separate groups. The
+// types are searched concurrently, each with its own timeout; a type that fails
+// reports an error and the other groups are still returned.
+func (sc *SearchController) SearchAll(c *gin.Context) {
+	start := time.Now()
+
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultGroupSize)))
+	if err != nil || limit < 1 {
+		limit = defaultGroupSize
+	}
+	if limit > maxGroupSize {
+		limit = maxGroupSize
+	}
+
+	lang := c.Query("lang")
+	if lang != "" && !analysis.IsSupportedLanguage(lang) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lang, expected en, fr or ar"})
+		return
+	}
+
+	mode := c.DefaultQuery("mode", searchModeText)
+	if mode != searchModeText && mode != searchModeSemantic && mode != searchModeHybrid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode, expected text, semantic or hybrid"})
+		return
+	}
+
+	parsed := analysis.ParseEntityQuery(query)
+	if parsed.Text == "" && len(parsed.Hashtags) == 0 && len(parsed.Mentions) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+	if mode != searchModeText && strings.TrimSpace(parsed.Text) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Semantic and hybrid search require words besides #hashtags and @mentions"})
+		return
+	}
+
+	event := models.SearchEvent{
+		Endpoint: "search_all",
+		Query:    query,
+		Filters:  map[string]any{"lang": lang, "size": limit, "mode": mode},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	viewer := resolveViewer(ctx, c)
+
+	cacheKey := fmt.Sprintf("search:all:%s:%s:%s:%s:%d", viewer.CacheScope(), mode, query, lang, limit)
+	if cachedResults, err := getCachedResults(cacheKey); err == nil {
+		event.ResultCount = countOf(cachedResults["total"])
+		event.CacheHit = true
+		event.PublicMatch = takePublicMatch(cachedResults)
+		if event.ResultCount > 0 {
+			recordSuccessfulQuery(query, "", event.PublicMatch)
+		}
+		recordRecentSearch(c, query)
+		enrichCachedResponse(ctx, cachedResults)
+		respondWithSearchEvent(c, cachedResults, event, start)
+		return
+	}
+
+	groups := make([]searchGroup, len(groupedTypes))
+	var wg sync.WaitGroup
+	for i, contentType := range groupedTypes {
+		wg.Add(1)
+		go func(i int, contentType models.ContentType) {
+			defer wg.Done()
+			groups[i] = searchContentType(ctx, contentType, mode, viewer, parsed, query, lang, int64(limit))
+		}(i, contentType)
+	}
+	wg.Wait()
+
+	total, failed, publicMatch := 0, 0, false
+	var results []models.SearchResult
+	for _, group := range groups {
+		total += group.Total
+		if group.Error != "" {
+			failed++
+		}
+		publicMatch = publicMatch || group.Public
+		results = append(results, group.Results...)
+	}
+
+	partial := failed > 0
+
+	// Remember the query in the authenticated user's history unless every type failed
+	if failed < len(groups) {
+		recordRecentSearch(c, query)
+	}
+
+	responseData := gin.H{
+		"query":   query,
+		"groups":  groups,
+		"total":   total,
+		"partial": partial,
+	}
+
+	// Partial responses are not cached, so the next request retries the failed types
+	if !partial {
+		cacheWithPublicMatch(cacheKey, responseData, publicMatch)
+		trackCachedResults(cacheKey, results)
+	}
+	enrichGroups(ctx, groups)
+
+	event.ResultCount = total
+	event.PublicMatch = publicMatch
+	if total > 0 {
+		recordSuccessfulQuery(query, "", publicMatch)
+	}
+	respondWithSearchEvent(c, responseData, event, start)
+}
+
+// searchContentType runs the top hits search of one content type for SearchAll,
+// with the same filters as Search and people search for users
+func searchContentType(parent context.Context, contentType models.ContentType, mode string, viewer searchViewer,
+	parsed analysis.EntityQuery, query, lang string, limit int64) searchGroup {
+	ctx, cancel := context.WithTimeout(parent, groupTimeout)
+	defer cancel()
+
+	group := searchGroup{Type: contentType, Results: []models.SearchResult{}}
+
+	peopleMode := contentType == models.User && mode == searchModeText
+	if peopleMode {
+		parsed = peopleQuery(parsed)
+	}
+
+	filter := entityFilter(parsed, lang)
+	filter["content_type"] = contentType
+	filter["nsfw"] = bson.M{"$ne": true}
+	andFilter(filter, viewer.Filter())
+	andFilter(filter, visibleFilter())
+
+	var documents []rankedDocument
+	var err error
+	if peopleMode {
+		documents, _, err = peopleSearch(ctx, viewer.UserID, filter, parsed.Text, 0, limit)
+	} else {
+		textFilter := filter
+		if mode != searchModeSemantic {
+			textFilter = withTextSearch(filter, textSearchString(parsed.Text), lang)
+		}
+		documents, _, err = runSearch(ctx, RankingConfig, mode, textFilter, filter, parsed.Text, false, 0, limit)
+	}
+	if err != nil {
+		log.Printf("Grouped search error for %s: %v", contentType, err)
+		group.Error = "Failed to search " + string(contentType)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			group.Error = "Timed out searching " + string(contentType)
+		}
+		return group
+	}
+
+	for _, document := range documents {
+		group.Results = append(group.Results, newSearchResult(document, query))
+	}
+	group.Public = hasPublicDocument(documents)
+	attachParents(ctx, viewer, group.Results)
+	group.Total = len(group.Results)
+	return group
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"circleconnect-search/models"
+	"circleconnect-search/ranking"
+)
+
+// Geo search limits
+const (
+	defaultGeoRadiusKm = 25.0
+	maxGeoRadiusKm     = 1000.0
+)
+
+// parseGeoOrigin reads the near=lat,lng and radius (km) parameters. It returns
+// nil when no near point is given.
+func parseGeoOrigin(c *gin.Context) (*ranking.GeoOrigin, error) {
+	near, radiusParam := c.Query("near"), c.Query("radius")
+	if near == "" {
+		if radiusParam != "" {
+			return nil, fmt.Errorf("radius requires near")
+		}
+		return nil, nil
+	}
+
+	parts := strings.Split(near, ",")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid near, expected lat,lng")
+	}
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lng, lngErr := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if latErr != nil || lngErr != nil || !validCoordinates(lat, lng) {
+		return nil, fmt.Errorf("invalid near, expected lat,lng")
+	}
+
+	radius := defaultGeoRadiusKm
+	if radiusParam != "" {
+		var err error
+		radius, err = strconv.ParseFloat(radiusParam, 64)
+		if err != nil || radius <= 0 || radius > maxGeoRadiusKm {
+			return nil, fmt.Errorf("invalid radius, expected kilometers between 0 and 1000")
+		}
+	}
+	return &ranking.GeoOrigin{Lat: lat, Lng: lng, RadiusKm: radius}, nil
+}
+
+// validCoordinates reports whether a latitude and longitude are in range
+func validCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// validateLocation checks an indexed location, defaulting its GeoJSON type
+func validateLocation(location *models.GeoPoint) error {
+	if location == nil {
+		return nil
+	}
+	if location.Type == "" {
+		location.Type = "Point"
+	}
+	if location.Type != "Point" {
+		return fmt.Errorf("unsupported location type %s, expected Point", location.Type)
+	}
+	if !validCoordinates(location.Lat(), location.Lng()) {
+		return fmt.Errorf("invalid location, coordinates are [longitude, latitude]")
+	}
+	return nil
+}
+
+// geoCacheKey identifies a geo search origin in result cache keys
+func geoCacheKey(origin *ranking.GeoOrigin) string {
+	if origin == nil {
+		return "any"
+	}
+	return fmt.Sprintf("%.5f,%.5f,%g", origin.Lat, origin.Lng, origin.RadiusKm)
+}
+
+// resultDistance returns the distance of a document from the origin in
+// kilometers, rounded to 10 meters, or nil when either is unknown
+func resultDistance(origin *ranking.GeoOrigin, document models.SearchIndex) *float64 {
+	if origin == nil || document.Location == nil {
+		return nil
+	}
+	distance := ranking.DistanceKm(origin.Lat, origin.Lng, document.Location.Lat(), document.Location.Lng())
+	distance = math.Round(distance*100) / 100
+	return &distance
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/database"
+	"circleconnect-search/models"
+)
+
+// Hashtags looks up hashtags by prefix with the number of documents using each
+func (sc *SearchController) Hashtags(c *gin.Context) {
+	// The prefix is optional; without it the most used hashtags are returned
+	prefix := strings.ToLower(strings.TrimPrefix(c.Query("prefix"), "#"))
+	contentType := c.Query("type")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(maxSuggestions)))
+	if err != nil || limit < 1 {
+		limit = maxSuggestions
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	// Try to get cached hashtags
+	cacheKey := fmt.Sprintf("hashtags:%s:%s:%d", prefix, contentType, limit)
+	cachedHashtags, err := getCachedResults(cacheKey)
+	if err == nil {
+		c.JSON(http.StatusOK, cachedHashtags)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Hashtags are stored lowercase, so an anchored regex can use hashtags_index
+	match := listedFilter()
+	if prefix != "" {
+		match["hashtags"] = bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}
+	}
+	if contentType != "" {
+		match["content_type"] = contentType
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$project": bson.M{"hashtags": 1}},
+		{"$unwind": "$hashtags"},
+	}
+
+	// Drop the other hashtags of matching documents
+	if prefix != "" {
+		pipeline = append(pipeline, bson.M{
+			"$match": bson.M{"hashtags": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}},
+		})
+	}
+
+	pipeline = append(pipeline,
+		bson.M{"$group": bson.M{"_id": "$hashtags", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": limit},
+	)
+
+	cursor, err := database.MongoDB.Collection("search_index").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("Hashtag lookup error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch hashtags"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	hashtags := []models.HashtagCount{}
+	if err := cursor.All(ctx, &hashtags); err != nil {
+		log.Printf("Error processing hashtags: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process hashtags"})
+		return
+	}
+
+	responseData := gin.H{
+		"hashtags": hashtags,
+		"prefix":   prefix,
+		"count":    len(hashtags),
+	}
+
+	cacheResults(cacheKey, responseData)
+
+	c.JSON(http.StatusOK, responseData)
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/ranking"
+)
+
+// maxHybridCandidates caps the hits taken from each list, which bounds how deep
+// hybrid results can be paged
+const maxHybridCandidates = 1000
+
+// hybridSearch ranks documents by fusing the text ranking of textFilter with the
+// semantic ranking of filter, then returns one page of the fused list along with
+// the text pipeline that ran. When the query can't be embedded the text hits are
+// returned alone. With collapse, the fused list is collapsed by thread before
+// paging.
+func hybridSearch(ctx context.Context, cfg ranking.Config, textFilter, filter bson.M, text string, collapse bool, skip, limit int64) ([]rankedDocument, []bson.M, error) {
+	depth := int64(cfg.Fusion.Candidates)
+	if depth < skip+limit {
+		depth = skip + limit
+	}
+	if depth > maxHybridCandidates {
+		depth = maxHybridCandidates
+	}
+
+	pipeline := cfg.Pipeline(textFilter, time.Now(), 0, depth)
+	textDocuments, err := aggregateRanked(ctx, pipeline)
+	if err != nil {
+		return nil, pipeline, err
+	}
+
+	semanticDocuments, err := semanticSearch(ctx, filter, text, 0, depth)
+	if err != nil {
+		log.Printf("Semantic search error, using text hits only: %v", err)
+		semanticDocuments = nil
+	}
+
+	// Keep the text ranking fields and the similarity of documents in both lists
+	documents := map[string]rankedDocument{}
+	textHits := make([]ranking.FusionHit, len(textDocuments))
+	for i, document := range textDocuments {
+		id := document.ID.Hex()
+		documents[id] = document
+		textHits[i] = ranking.FusionHit{ID: id, ContentType: string(document.ContentType), Score: document.Score}
+	}
+	semanticHits := make([]ranking.FusionHit, len(semanticDocuments))
+	for i, document := range semanticDocuments {
+		id := document.ID.Hex()
+		if existing, ok := documents[id]; ok {
+			existing.Similarity = document.Similarity
+			document = existing
+		}
+		documents[id] = document
+		semanticHits[i] = ranking.FusionHit{ID: id, ContentType: string(document.ContentType), Score: document.Similarity}
+	}
+
+	fused := cfg.Fusion.Fuse(textHits, semanticHits)
+	ranked := make([]rankedDocument, len(fused))
+	for i, hit := range fused {
+		document := documents[hit.ID]
+		document.Score = hit.Score
+		document.TextRank, document.SemanticRank = hit.TextRank, hit.SemanticRank
+		ranked[i] = document
+	}
+	if collapse {
+		ranked = collapseThreads(ranked)
+	}
+
+	if skip >= int64(len(ranked)) {
+		return nil, pipeline, nil
+	}
+	ranked = ranked[skip:]
+	if int64(len(ranked)) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked, pipeline, nil
+}
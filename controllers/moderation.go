@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/database"
+	"circleconnect-search/middleware"
+	"circleconnect-search/models"
+)
+
+// Moderation limits
+const (
+	maxModerationItems = 500
+	cacheRefTTL        = 10 * time.Minute // Matches the result cache TTL
+)
+
+// visibleFilter matches documents moderation allows to be listed, leaving out
+// users who hid themselves from search. Documents indexed before moderation
+// existed have no state and are visible.
+func visibleFilter() bson.M {
+	return bson.M{
+		"moderation_state":   bson.M{"$in": []any{nil, models.ModerationVisible}},
+		"hidden_from_search": bson.M{"$ne": true},
+	}
+}
+
+// listedFilter matches documents that may appear in shared listings such as
+// hashtag counts and trending: public, visible and safe for work, like
+// SearchIndex.IsListed
+func listedFilter() bson.M {
+	filter := publicFilter()
+	for key, value := range visibleFilter() {
+		filter[key] = value
+	}
+	filter["nsfw"] = bson.M{"$ne": true}
+	return filter
+}
+
+// cacheRefKey is the Redis set of cache keys whose results include a content ID
+func cacheRefKey(contentID string) string {
+	return "cache:refs:" + contentID
+}
+
+// trackCachedResults remembers which cache entry holds each result, and the
+// post of each comment result, so the entry can be invalidated when the content
+// is moderated or deleted
+func trackCachedResults(cacheKey string, results []models.SearchResult) {
+	if RedisClient == nil || len(results) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	pipe := RedisClient.Pipeline()
+	for _, result := range results {
+		contentIDs := []string{result.ContentID}
+		if result.Parent != nil {
+			contentIDs = append(contentIDs, result.Parent.ContentID)
+		}
+		for _, contentID := range contentIDs {
+			refKey := cacheRefKey(contentID)
+			pipe.SAdd(ctx, refKey, cacheKey)
+			pipe.Expire(ctx, refKey, cacheRefTTL)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Error tracking cached results: %v", err)
+	}
+}
+
+// invalidateContentCache deletes every cached result set that includes one of
+// the content IDs, and returns how many entries were deleted
+func invalidateContentCache(ctx context.Context, contentIDs []string) int {
+	if RedisClient == nil || len(contentIDs) == 0 {
+		return 0
+	}
+
+	var keys []string
+	for _, contentID := range contentIDs {
+		refKey := cacheRefKey(contentID)
+		members, err := RedisClient.SMembers(ctx, refKey).Result()
+		if err != nil {
+			log.Printf("Error reading cache refs: %v", err)
+			continue
+		}
+		keys = append(keys, members...)
+		keys = append(keys, refKey)
+	}
+	if len(keys) == 0 {
+		return 0
+	}
+
+	deleted, err := RedisClient.Del(ctx, keys...).Result()
+	if err != nil {
+		log.Printf("Error invalidating cached results: %v", err)
+		return 0
+	}
+	return int(deleted)
+}
+
+// Moderate changes the moderation state or NSFW flag of many documents at once
+// and invalidates the cached results that include them
+func (sc *SearchController) Moderate(c *gin.Context) {
+	var request struct {
+		Items []struct {
+			ContentID   string             `json:"content_id" binding:"required"`
+			ContentType models.ContentType `json:"content_type"`
+		} `json:"items" binding:"required,min=1,dive"`
+		State string `json:"state" binding:"omitempty,oneof=visible pending hidden removed"`
+		NSFW  *bool  `json:"nsfw"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(request.Items) > maxModerationItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many items, the maximum is 500"})
+		return
+	}
+	if request.State == "" && request.NSFW == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state or nsfw is required"})
+		return
+	}
+
+	// Record who moderated: the moderator's user ID, or the calling service
+	moderatedBy := "service"
+	if user, ok := middleware.CurrentUser(c); ok {
+		moderatedBy = user.ID
+	}
+
+	refs := make([]bson.M, 0, len(request.Items))
+	contentIDs := make([]string, 0, len(request.Items))
+	for _, item := range request.Items {
+		ref := bson.M{"content_id": item.ContentID}
+		if item.ContentType != "" {
+			ref["content_type"] = item.ContentType
+		}
+		refs = append(refs, ref)
+		contentIDs = append(contentIDs, item.ContentID)
+	}
+
+	update := bson.M{"moderated_at": time.Now(), "moderated_by": moderatedBy}
+	if request.State != "" {
+		update["moderation_state"] = request.State
+	}
+	if request.NSFW != nil {
+		update["nsfw"] = *request.NSFW
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := database.MongoDB.Collection("search_index").UpdateMany(ctx, bson.M{"$or": refs}, bson.M{"$set": update})
+	if err != nil {
+		log.Printf("Moderation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update moderation state"})
+		return
+	}
+
+	invalidated := invalidateContentCache(ctx, contentIDs)
+
+	// Content that can no longer be listed leaves autocomplete
+	if (request.State != "" && request.State != models.ModerationVisible) || (request.NSFW != nil && *request.NSFW) {
+		documents, err := suggestionSources(ctx, bson.M{"$or": refs})
+		if err != nil {
+			log.Printf("Error loading moderated content suggestions: %v", err)
+		}
+		retractSuggestionsAsync(documents)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Moderation state updated successfully",
+		"matched":     result.MatchedCount,
+		"modified":    result.ModifiedCount,
+		"invalidated": invalidated,
+	})
+}
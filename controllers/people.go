@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/database"
+	"circleconnect-search/models"
+)
+
+// People search tuning
+const (
+	userConnectionsTable = "user_connections"
+	maxPeopleCandidates  = 200  // Name matches re-ranked with mutual connections
+	exactUsernameScore   = 100  // Query is the username
+	usernamePrefixScore  = 50   // Username starts with the query
+	exactNameScore       = 40   // Query is the whole display name
+	nameTokenScore       = 10   // Per query word that is a whole display name word
+	namePrefixScore      = 20   // Every query word starts a display name word
+	mutualWeight         = 10.0 // Multiplier for log2(1 + mutual connections)
+	peoplePopularity     = 5.0  // Multiplier for log10(1 + popularity_score)
+)
+
+// prepareUserDocument fills the people search fields of a user document and
+// removes email addresses, which must never be searchable or shown
+func prepareUserDocument(doc *models.SearchIndex) {
+	doc.Title = analysis.RedactEmails(doc.Title)
+	doc.Content = analysis.RedactEmails(doc.Content)
+	doc.DisplayName = analysis.RedactEmails(doc.DisplayName)
+	for key := range doc.Metadata {
+		if strings.Contains(strings.ToLower(key), "email") {
+			delete(doc.Metadata, key)
+		}
+	}
+
+	doc.Username = analysis.NormalizeUsername(doc.Username)
+	if doc.Username == "" {
+		doc.Username = analysis.NormalizeUsername(doc.ContentID)
+	}
+	if doc.DisplayName == "" {
+		doc.DisplayName = doc.Title
+	}
+	if doc.Title == "" {
+		doc.Title = doc.DisplayName
+	}
+	doc.NameTokens = analysis.NameTokens(doc.DisplayName)
+}
+
+// redactEmailList removes email addresses from each value, dropping values
+// that were nothing but an address
+func redactEmailList(values []string) []string {
+	var redacted []string
+	for _, value := range values {
+		if value = strings.TrimSpace(analysis.RedactEmails(value)); value != "" {
+			redacted = append(redacted, value)
+		}
+	}
+	return redacted
+}
+
+// peopleQuery treats @user tokens as part of the name being searched for
+// rather than as mention filters
+func peopleQuery(parsed analysis.EntityQuery) analysis.EntityQuery {
+	parsed.Text = strings.TrimSpace(strings.Join(append([]string{parsed.Text}, parsed.Mentions...), " "))
+	parsed.Mentions = nil
+	return parsed
+}
+
+// peopleSearch ranks user documents matching the filter by username and display
+// name: exact usernames first, then username prefixes, then names whose words
+// start with every query word. For a signed-in viewer, people sharing more
+// connections with them rank higher. The name matching pipeline is returned
+// for explain output.
+func peopleSearch(ctx context.Context, viewerID string, filter bson.M, text string, skip, limit int64) ([]rankedDocument, []bson.M, error) {
+	terms := analysis.NameTokens(text)
+	if len(terms) == 0 {
+		return nil, nil, nil
+	}
+	username := analysis.NormalizeUsername(text)
+
+	prefixClauses := make([]bson.M, len(terms))
+	for i, term := range terms {
+		prefixClauses[i] = bson.M{"name_tokens": bson.M{"$regex": "^" + regexp.QuoteMeta(term)}}
+	}
+	matches := []bson.M{{"$and": prefixClauses}}
+	if !strings.ContainsAny(username, " \t") {
+		matches = append(matches, bson.M{"username": bson.M{"$regex": "^" + regexp.QuoteMeta(username)}})
+	}
+
+	peopleFilter := bson.M{}
+	for key, value := range filter {
+		peopleFilter[key] = value
+	}
+	peopleFilter["content_type"] = models.User
+	andFilter(peopleFilter, bson.M{"$or": matches})
+
+	scoreIf := func(condition any, score int) bson.M {
+		return bson.M{"$cond": []any{condition, score, 0}}
+	}
+	nameTokens := bson.M{"$ifNull": []any{"$name_tokens", []string{}}}
+	allPrefixed := bson.M{"$allElementsTrue": []any{bson.M{"$map": bson.M{
+		"input": terms,
+		"as":    "term",
+		"in": bson.M{"$anyElementTrue": []any{bson.M{"$map": bson.M{
+			"input": nameTokens,
+			"as":    "token",
+			"in":    bson.M{"$eq": []any{bson.M{"$indexOfCP": []any{"$$token", "$$term"}}, 0}},
+		}}}},
+	}}}}
+
+	pipeline := []bson.M{
+		{"$match": peopleFilter},
+		{"$addFields": bson.M{"score": bson.M{"$add": []any{
+			scoreIf(bson.M{"$eq": []any{"$username", username}}, exactUsernameScore),
+			scoreIf(bson.M{"$eq": []any{bson.M{"$indexOfCP": []any{bson.M{"$ifNull": []any{"$username", ""}}, username}}, 0}}, usernamePrefixScore),
+			scoreIf(bson.M{"$eq": []any{nameTokens, terms}}, exactNameScore),
+			bson.M{"$multiply": []any{nameTokenScore, bson.M{"$size": bson.M{"$setIntersection": []any{nameTokens, terms}}}}},
+			scoreIf(allPrefixed, namePrefixScore),
+			bson.M{"$multiply": []any{peoplePopularity, bson.M{"$log10": bson.M{"$add": []any{
+				1, bson.M{"$max": []any{0, bson.M{"$ifNull": []any{"$popularity_score", 0}}}},
+			}}}}},
+		}}}},
+		{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}},
+		{"$limit": maxPeopleCandidates},
+		{"$project": bson.M{"embedding": 0}},
+	}
+
+	documents, err := aggregateRanked(ctx, pipeline)
+	if err != nil {
+		return nil, pipeline, err
+	}
+
+	// Boost people the viewer shares connections with, then paginate
+	if viewerID != "" && len(documents) > 0 {
+		userIDs := make([]string, len(documents))
+		for i, document := range documents {
+			userIDs[i] = document.ContentID
+		}
+		mutuals, err := mutualConnections(ctx, viewerID, userIDs)
+		if err != nil {
+			log.Printf("Error loading mutual connections: %v", err)
+		}
+		for i := range documents {
+			documents[i].Score += mutualWeight * math.Log2(1+float64(mutuals[documents[i].ContentID]))
+		}
+		sort.SliceStable(documents, func(i, j int) bool {
+			return documents[i].Score > documents[j].Score
+		})
+	}
+
+	if skip >= int64(len(documents)) {
+		return nil, pipeline, nil
+	}
+	documents = documents[skip:]
+	if int64(len(documents)) > limit {
+		documents = documents[:limit]
+	}
+	return documents, pipeline, nil
+}
+
+// mutualConnections counts, for each of the users, the connections they share
+// with the viewer, read from the Postgres user_connections table
+func mutualConnections(ctx context.Context, viewerID string, userIDs []string) (map[string]int, error) {
+	if database.PgDB == nil {
+		return nil, fmt.Errorf("postgres not connected")
+	}
+
+	var rows []struct {
+		UserID  string
+		Mutuals int
+	}
+	err := database.PgDB.WithContext(ctx).
+		Table(userConnectionsTable+" AS mine").
+		Select("theirs.user_id AS user_id, COUNT(*) AS mutuals").
+		Joins("JOIN "+userConnectionsTable+" AS theirs ON theirs.connected_user_id = mine.connected_user_id").
+		Where("mine.user_id = ? AND theirs.user_id IN ? AND theirs.user_id <> ?", viewerID, userIDs, viewerID).
+		Group("theirs.user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	mutuals := make(map[string]int, len(rows))
+	for _, row := range rows {
+		mutuals[row.UserID] = row.Mutuals
+	}
+	return mutuals, nil
+}
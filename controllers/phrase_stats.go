@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/database"
+)
+
+// Corpus statistics for phrase scoring
+const (
+	maxPhrasesPerDoc = 20 // Extracted phrases kept per document, besides title, tags and hashtags
+
+	// corpusDocsKey is the phrase_stats entry counting documents. Candidates are
+	// made of letters and digits only, so it never collides with a term.
+	corpusDocsKey = "__docs__"
+)
+
+// phraseStat is a phrase_stats document: the number of documents containing a term
+type phraseStat struct {
+	Term    string `bson:"_id"`
+	DocFreq int    `bson:"df"`
+}
+
+// loadCorpusStats fetches the document frequencies of the candidates and the corpus size
+func loadCorpusStats(ctx context.Context, candidates map[string]float64) (analysis.CorpusStats, error) {
+	stats := analysis.CorpusStats{DocFreq: make(map[string]int, len(candidates))}
+	if database.MongoDB == nil || len(candidates) == 0 {
+		return stats, nil
+	}
+
+	terms := make([]string, 0, len(candidates)+1)
+	terms = append(terms, corpusDocsKey)
+	for term := range candidates {
+		terms = append(terms, term)
+	}
+
+	cursor, err := database.MongoDB.Collection("phrase_stats").Find(ctx, bson.M{"_id": bson.M{"$in": terms}})
+	if err != nil {
+		return stats, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var stat phraseStat
+		if err := cursor.Decode(&stat); err != nil {
+			continue
+		}
+		if stat.Term == corpusDocsKey {
+			stats.Docs = stat.DocFreq
+		} else {
+			stats.DocFreq[stat.Term] = stat.DocFreq
+		}
+	}
+	return stats, cursor.Err()
+}
+
+// updateCorpusStatsAsync counts a newly indexed document in the corpus statistics
+func updateCorpusStatsAsync(candidates map[string]float64) {
+	if database.MongoDB == nil || len(candidates) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		writes := make([]mongo.WriteModel, 0, len(candidates)+1)
+		writes = append(writes, incrementDocFreq(corpusDocsKey))
+		for term := range candidates {
+			writes = append(writes, incrementDocFreq(term))
+		}
+
+		_, err := database.MongoDB.Collection("phrase_stats").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			log.Printf("Error updating phrase statistics: %v", err)
+		}
+	}()
+}
+
+// incrementDocFreq returns an upsert adding one document to a term's frequency
+func incrementDocFreq(term string) mongo.WriteModel {
+	return mongo.NewUpdateOneModel().
+		SetFilter(bson.M{"_id": term}).
+		SetUpdate(bson.M{"$inc": bson.M{"df": 1}}).
+		SetUpsert(true)
+}
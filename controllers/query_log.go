@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"circleconnect-search/database"
+	"circleconnect-search/middleware"
+	"circleconnect-search/models"
+)
+
+// newSearchID returns an ID the client echoes back when reporting clicks
+func newSearchID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+// normalizeQuery lowercases a query and collapses whitespace so equivalent
+// searches are logged and counted together
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// logSearchEvent writes a query-log event in the background so logging never
+// adds latency to the search response
+func logSearchEvent(c *gin.Context, event models.SearchEvent) {
+	if database.MongoDB == nil {
+		return
+	}
+
+	if user, ok := middleware.CurrentUser(c); ok {
+		event.UserID = user.ID
+	}
+	event.Query = normalizeQuery(event.Query)
+	event.CreatedAt = time.Now()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := database.MongoDB.Collection("search_queries").InsertOne(ctx, event); err != nil {
+			log.Printf("Error logging search event: %v", err)
+		}
+	}()
+}
+
+// publicMatchKey marks cached responses whose results include public content,
+// so cache hits feed suggestions like the search that filled the cache did
+const publicMatchKey = "public_match"
+
+// recordSuccessfulQuery feeds a search that returned results into trending and
+// autocomplete. Only queries that matched public content are shared with
+// everyone; a query that only matched content the viewer may see stays private.
+func recordSuccessfulQuery(query string, contentType string, publicMatch bool) {
+	if !publicMatch {
+		return
+	}
+	recordTrendingTerms([]string{query}, contentType)
+	feedSuggestionsAsync([]suggestionEntry{{Phrase: query, Weight: 1, Source: "query"}}, contentType)
+}
+
+// hasPublicDocument reports whether any ranked document is public
+func hasPublicDocument(documents []rankedDocument) bool {
+	for _, document := range documents {
+		if document.IsPublic() {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheWithPublicMatch caches a response along with whether its results include
+// public content, without adding the marker to the response itself
+func cacheWithPublicMatch(key string, responseData gin.H, publicMatch bool) {
+	responseData[publicMatchKey] = publicMatch
+	cacheResults(key, responseData)
+	delete(responseData, publicMatchKey)
+}
+
+// takePublicMatch removes the public content marker from a cached response and
+// returns it; entries cached without it count as private
+func takePublicMatch(cachedResults gin.H) bool {
+	publicMatch, _ := cachedResults[publicMatchKey].(bool)
+	delete(cachedResults, publicMatchKey)
+	return publicMatch
+}
+
+// respondWithSearchEvent attaches a fresh search_id to the response, logs the
+// matching query event and writes the response. Call it after caching so the
+// search_id is never stored in Redis.
+func respondWithSearchEvent(c *gin.Context, responseData gin.H, event models.SearchEvent, start time.Time) {
+	event.SearchID = newSearchID()
+	event.LatencyMs = time.Since(start).Milliseconds()
+	responseData["search_id"] = event.SearchID
+
+	logSearchEvent(c, event)
+	c.JSON(http.StatusOK, responseData)
+}
+
+// countOf returns the number of results a response field represents, whether
+// it holds a count or a list (cached responses come back as float64 and []any)
+func countOf(value any) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case []any:
+		return len(v)
+	case []string:
+		return len(v)
+	case []models.SearchResult:
+		return len(v)
+	}
+	return 0
+}
+
+// Click records a click on a search result, tied to the search_id returned by Search or Recommend
+func (sc *SearchController) Click(c *gin.Context) {
+	var click models.ClickEvent
+	if err := c.ShouldBindJSON(&click); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := primitive.ObjectIDFromHex(click.SearchID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search_id"})
+		return
+	}
+
+	click.ID = primitive.NilObjectID
+	click.UserID = ""
+	if user, ok := middleware.CurrentUser(c); ok {
+		click.UserID = user.ID
+	}
+	click.CreatedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := database.MongoDB.Collection("search_clicks").InsertOne(ctx, click); err != nil {
+		log.Printf("Click logging error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record click"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Click recorded successfully"})
+}
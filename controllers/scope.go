@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// searchScope limits a search to the content of one community and/or author,
+// e.g. for the search box of a community page. The zero value searches everything.
+type searchScope struct {
+	CommunityID string
+	Author      string
+}
+
+// Apply restricts the filter to the scope
+func (s searchScope) Apply(filter bson.M) {
+	if s.CommunityID != "" {
+		filter["community_id"] = s.CommunityID
+	}
+	if s.Author != "" {
+		filter["author"] = s.Author
+	}
+}
+
+// CacheKey identifies the scope in result cache keys, so scoped results are
+// cached separately from each other and from unscoped ones
+func (s searchScope) CacheKey() string {
+	if s.CommunityID == "" && s.Author == "" {
+		return "all"
+	}
+	var parts []string
+	if s.CommunityID != "" {
+		parts = append(parts, "community="+url.QueryEscape(s.CommunityID))
+	}
+	if s.Author != "" {
+		parts = append(parts, "author="+url.QueryEscape(s.Author))
+	}
+	return strings.Join(parts, "&")
+}
+
+// EventFilters describes the scope for the query log
+func (s searchScope) EventFilters() map[string]any {
+	filters := map[string]any{}
+	if s.CommunityID != "" {
+		filters["community_id"] = s.CommunityID
+	}
+	if s.Author != "" {
+		filters["author"] = s.Author
+	}
+	return filters
+}
+
+// SearchCommunity searches the content posted in one community
+func (sc *SearchController) SearchCommunity(c *gin.Context) {
+	sc.search(c, searchScope{CommunityID: c.Param("id"), Author: strings.TrimSpace(c.Query("author"))})
+}
+
+// SearchAuthor searches the content written by one user
+func (sc *SearchController) SearchAuthor(c *gin.Context) {
+	sc.search(c, searchScope{CommunityID: strings.TrimSpace(c.Query("community_id")), Author: c.Param("id")})
+}
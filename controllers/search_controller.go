@@ -6,26 +6,31 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"circleconnect-search/analysis"
 	"circleconnect-search/database"
+	"circleconnect-search/middleware"
 	"circleconnect-search/models"
+	"circleconnect-search/ranking"
 )
 
 // RedisClient is used for caching search results
 var RedisClient *redis.Client
 
+// RankingConfig controls search ranking and hybrid fusion. main replaces the
+// default with the file named by RANKING_CONFIG.
+var RankingConfig = ranking.DefaultConfig()
+
 // Default values for pagination
 const (
 	defaultPage     = 1
@@ -37,8 +42,19 @@ const (
 // SearchController handles search operations
 type SearchController struct{}
 
-// Search handles search requests
+// Search handles search requests, optionally scoped to a community or author
+// with the community_id and author parameters
 func (sc *SearchController) Search(c *gin.Context) {
+	sc.search(c, searchScope{
+		CommunityID: strings.TrimSpace(c.Query("community_id")),
+		Author:      strings.TrimSpace(c.Query("author")),
+	})
+}
+
+// search runs a search within a scope
+func (sc *SearchController) search(c *gin.Context, scope searchScope) {
+	start := time.Now()
+
 	// Extract search query parameters
 	query := c.Query("q")
 	if query == "" {
@@ -52,73 +68,277 @@ func (sc *SearchController) Search(c *gin.Context) {
 	// Parse content type filter
 	contentType := c.Query("type")
 
-	// Try to get cached results
-	cacheKey := fmt.Sprintf("search:%s:%s:%d:%d", query, contentType, page, pageSize)
-	cachedResults, err := getCachedResults(cacheKey)
-	if err == nil {
-		c.JSON(http.StatusOK, cachedResults)
+	// Parse language filter
+	lang := c.Query("lang")
+	if lang != "" && !analysis.IsSupportedLanguage(lang) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lang, expected en, fr or ar"})
+		return
+	}
+
+	// Explain mode exposes ranking internals, so only internal services may use it
+	explain := c.Query("explain") == "true"
+	if explain && !middleware.IsServiceRequest(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Explain mode requires a service API key"})
+		return
+	}
+
+	// Text mode ranks keyword matches, semantic mode ranks by embedding similarity
+	// and hybrid mode fuses both rankings
+	mode := c.DefaultQuery("mode", searchModeText)
+	if mode != searchModeText && mode != searchModeSemantic && mode != searchModeHybrid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode, expected text, semantic or hybrid"})
+		return
+	}
+
+	// Only visible content is searched by default; moderators may include other states
+	states := []string{models.ModerationVisible}
+	if stateParam := c.Query("state"); stateParam != "" {
+		states = nil
+		for _, state := range strings.Split(stateParam, ",") {
+			state = strings.TrimSpace(state)
+			if !models.IsModerationState(state) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state, expected visible, pending, hidden or removed"})
+				return
+			}
+			states = analysis.MergeUnique(states, state)
+		}
+	}
+	moderatorView := len(states) != 1 || states[0] != models.ModerationVisible
+	if moderatorView && !middleware.HasRole(c, middleware.RoleAdmin, middleware.RoleModerator) && !middleware.IsServiceRequest(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Moderator access required to search non-visible content"})
+		return
+	}
+	sort.Strings(states)
+
+	// NSFW content is excluded unless requested
+	includeNSFW := c.Query("nsfw") == "true"
+
+	// collapse=post groups comment hits of the same post into one result
+	collapse := false
+	switch c.Query("collapse") {
+	case "":
+	case "post":
+		collapse = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collapse, expected post"})
+		return
+	}
+	if collapse && mode == searchModeSemantic {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "collapse=post is not supported in semantic mode"})
+		return
+	}
+
+	// near=lat,lng and radius limit results to an area and rank closer ones higher
+	origin, err := parseGeoOrigin(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// time presets or from/to limit results by creation date
+	createdRange, err := parseTimeRange(c, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// histogram counts matches per day, week or month of creation
+	histogram := c.Query("histogram")
+	if histogram != "" && !histogramIntervals[histogram] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid histogram, expected day, week or month"})
+		return
+	}
+	if histogram != "" && (mode == searchModeSemantic || (contentType == string(models.User) && mode == searchModeText)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "histogram is not supported in semantic or people search"})
 		return
 	}
 
-	// Prepare search query
-	filter := bson.M{
-		"$text": bson.M{
-			"$search": query,
-		},
+	// Community filters only apply to communities
+	communityParams, err := parseCommunityFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if communityParams.IsSet() && contentType != "" && contentType != string(models.Community) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category, min_members and joinable require type=community"})
+		return
+	}
+
+	// Describe the request for the query log
+	event := models.SearchEvent{
+		Endpoint: "search",
+		Query:    query,
+		Filters: map[string]any{"type": contentType, "lang": lang, "page": page, "size": pageSize,
+			"state": strings.Join(states, ","), "nsfw": includeNSFW, "mode": mode, "collapse": collapse},
+	}
+	if explain {
+		event.Filters["explain"] = true
+	}
+	if createdRange.From != nil || createdRange.To != nil {
+		event.Filters["time"] = createdRange.CacheKey()
+	}
+	if histogram != "" {
+		event.Filters["histogram"] = histogram
+	}
+	if origin != nil {
+		event.Filters["near"] = c.Query("near")
+		event.Filters["radius"] = origin.RadiusKm
+	}
+	for key, value := range scope.EventFilters() {
+		event.Filters[key] = value
+	}
+	if communityParams.IsSet() {
+		event.Filters["category"] = strings.Join(communityParams.Categories, ",")
+		event.Filters["min_members"] = communityParams.MinMembers
+		event.Filters["joinable"] = communityParams.Joinable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Resolve who is searching; results only include content they may see
+	viewer := resolveViewer(ctx, c)
+
+	// Try to get cached results (explain output is never cached)
+	cacheKey := fmt.Sprintf("search:%s:%s:%s:%s:%s:%s:%s:%t:%s:%s:%s:%s:%t:%d:%d", viewer.CacheScope(), scope.CacheKey(), mode, query,
+		contentType, lang, strings.Join(states, ","), includeNSFW, communityParams.CacheKey(), geoCacheKey(origin),
+		createdRange.CacheKey(), histogram, collapse, page, pageSize)
+	if !explain {
+		cachedResults, err := getCachedResults(cacheKey)
+		if err == nil {
+			event.ResultCount = countOf(cachedResults["total"])
+			event.CacheHit = true
+			event.PublicMatch = takePublicMatch(cachedResults) && !moderatorView
+			if event.ResultCount > 0 {
+				recordSuccessfulQuery(query, contentType, event.PublicMatch)
+			}
+			recordRecentSearch(c, query)
+			enrichCachedResponse(ctx, cachedResults)
+			respondWithSearchEvent(c, cachedResults, event, start)
+			return
+		}
+	}
+
+	// Split #hashtag and @mention tokens out of the free-text query
+	parsed := analysis.ParseEntityQuery(query)
+	if parsed.Text == "" && len(parsed.Hashtags) == 0 && len(parsed.Mentions) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+	// People search matches usernames and names, where @user is the name searched for
+	peopleMode := contentType == string(models.User) && mode == searchModeText
+	if peopleMode {
+		parsed = peopleQuery(parsed)
+	}
+	if mode != searchModeText && strings.TrimSpace(parsed.Text) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Semantic and hybrid search require words besides #hashtags and @mentions"})
+		return
 	}
 
+	// Prepare search query with the language, #hashtag and @mention filters
+	filter := entityFilter(parsed, lang)
+	searchText := textSearchString(parsed.Text)
+
 	// Add content type filter if specified
 	if contentType != "" {
 		filter["content_type"] = contentType
 	}
 
-	// Setup options for pagination and sorting
-	findOptions := options.Find()
-	findOptions.SetLimit(int64(pageSize))
-	findOptions.SetSkip(int64((page - 1) * pageSize))
-	findOptions.SetSort(bson.M{"score": -1}) // Sort by relevance
-	findOptions.SetProjection(bson.M{
-		"score": bson.M{"$meta": "textScore"},
-	})
+	// Add category, size and joinability filters for communities
+	communityParams.Apply(filter)
 
-	// Execute search query
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// Limit results to the community or author being searched
+	scope.Apply(filter)
+
+	// Limit results to the creation date range
+	createdRange.Apply(filter)
+
+	// Limit results to the geo search area
+	if origin != nil {
+		for key, value := range origin.GeoFilter() {
+			filter[key] = value
+		}
+	}
 
-	cursor, err := database.MongoDB.Collection("search_index").Find(ctx, filter, findOptions)
+	// Restrict results to public content, the viewer's communities and their own content
+	andFilter(filter, viewer.Filter())
+
+	// Apply moderation state and NSFW filters
+	if moderatorView {
+		stateValues := []any{}
+		for _, state := range states {
+			stateValues = append(stateValues, state)
+			if state == models.ModerationVisible {
+				stateValues = append(stateValues, nil)
+			}
+		}
+		filter["moderation_state"] = bson.M{"$in": stateValues}
+	} else {
+		andFilter(filter, visibleFilter())
+	}
+	if !includeNSFW {
+		filter["nsfw"] = bson.M{"$ne": true}
+	}
+
+	// Text and hybrid mode match keywords; semantic mode compares embeddings instead
+	textFilter := filter
+	if mode != searchModeSemantic {
+		textFilter = withTextSearch(filter, searchText, lang)
+	}
+
+	// Execute search query in the requested mode
+	rankingConfig := RankingConfig
+	rankingConfig.Origin = origin
+	skip, limit := int64((page-1)*pageSize), int64(pageSize)
+	var documents []rankedDocument
+	var pipeline []bson.M
+	if peopleMode {
+		documents, pipeline, err = peopleSearch(ctx, viewer.UserID, filter, parsed.Text, skip, limit)
+	} else {
+		documents, pipeline, err = runSearch(ctx, rankingConfig, mode, textFilter, filter, parsed.Text, collapse, skip, limit)
+	}
 	if err != nil {
 		log.Printf("Search error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to execute search"})
 		return
 	}
-	defer cursor.Close(ctx)
 
 	// Process results
+	terms := ranking.QueryTerms(searchText)
 	var results []models.SearchResult
-	for cursor.Next(ctx) {
-		var document models.SearchIndex
-		if err := cursor.Decode(&document); err != nil {
-			log.Printf("Error decoding search result: %v", err)
-			continue
+	for _, document := range documents {
+		// Convert to search result
+		result := newSearchResult(document, query)
+		result.DistanceKm = resultDistance(origin, document.SearchIndex)
+		if moderatorView {
+			result.Moderation = document.ModerationState
+			if result.Moderation == "" {
+				result.Moderation = models.ModerationVisible
+			}
 		}
 
-		// Convert to search result
-		result := models.SearchResult{
-			ID:          document.ID.Hex(),
-			ContentID:   document.ContentID,
-			ContentType: document.ContentType,
-			Title:       document.Title,
-			Snippet:     createSnippet(document.Content, query),
-			Author:      document.Author,
-			CreatedAt:   document.CreatedAt,
-			UpdatedAt:   document.UpdatedAt,
-			Score:       document.Score,
+		if explain && mode == searchModeText && !peopleMode {
+			result.Explanation = rankingConfig.Explain(document.SearchIndex, terms,
+				document.TextScore, document.boosts())
+		}
+		if explain && mode == searchModeHybrid {
+			result.Explanation = &models.Explanation{}
+			if document.TextRank > 0 {
+				result.Explanation = rankingConfig.Explain(document.SearchIndex, terms,
+					document.TextScore, document.boosts())
+			}
+			result.Explanation.Similarity = document.Similarity
+			result.Explanation.TextRank = document.TextRank
+			result.Explanation.SemanticRank = document.SemanticRank
+			result.Explanation.FusedScore = document.Score
 		}
 
 		results = append(results, result)
 	}
 
-	// Cache results
+	// Give comment results the title of their post
+	attachParents(ctx, viewer, results)
+
 	responseData := gin.H{
 		"results": results,
 		"page":    page,
@@ -127,9 +347,209 @@ func (sc *SearchController) Search(c *gin.Context) {
 		"query":   query,
 	}
 
-	cacheResults(cacheKey, responseData)
+	// Count every match per interval for the histogram
+	if histogram != "" {
+		buckets, err := dateHistogram(ctx, textFilter, histogram)
+		if err != nil {
+			log.Printf("Histogram error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute histogram"})
+			return
+		}
+		responseData["histogram"] = gin.H{"interval": histogram, "buckets": buckets}
+	}
 
-	c.JSON(http.StatusOK, responseData)
+	// Remember the query in the authenticated user's history once the search succeeded
+	recordRecentSearch(c, query)
+
+	// Return the exact filter and pipeline that ran instead of caching
+	if explain {
+		explanation := gin.H{"mode": mode, "filter": extJSON(textFilter)}
+		if mode != searchModeText {
+			explanation["embedding_model"] = Embedder.Model()
+			explanation["min_similarity"] = minSemanticSimilarity
+		}
+		if peopleMode {
+			explanation["mode"] = "people"
+			explanation["pipeline"] = extJSON(pipeline)
+		} else if mode != searchModeSemantic {
+			explanation["pipeline"] = extJSON(pipeline)
+			explanation["ranking"] = rankingConfig
+		}
+		responseData["explain"] = explanation
+		enrichResults(ctx, results)
+		event.ResultCount = len(results)
+		respondWithSearchEvent(c, responseData, event, start)
+		return
+	}
+
+	// Cache results, remembering which entries to invalidate when a result is moderated
+	publicMatch := hasPublicDocument(documents)
+	cacheWithPublicMatch(cacheKey, responseData, publicMatch)
+	trackCachedResults(cacheKey, results)
+
+	// Author and community details are filled in after caching so they stay fresh
+	enrichResults(ctx, results)
+
+	// Moderator searches of hidden or removed content never feed suggestions
+	event.ResultCount = len(results)
+	event.PublicMatch = publicMatch && !moderatorView
+	if len(results) > 0 {
+		recordSuccessfulQuery(query, contentType, event.PublicMatch)
+	}
+	respondWithSearchEvent(c, responseData, event, start)
+}
+
+// AnonymousSearchFilter returns the filter a text mode Search runs for an
+// anonymous caller with the default moderation and NSFW settings, so the
+// relevance evaluation tool measures the results the endpoint serves. Its
+// in-memory ranking applies the same rules with SearchIndex.IsListed.
+func AnonymousSearchFilter(query, contentType string) bson.M {
+	parsed := analysis.ParseEntityQuery(query)
+	filter := entityFilter(parsed, "")
+	if contentType != "" {
+		filter["content_type"] = contentType
+	}
+	andFilter(filter, listedFilter())
+	return withTextSearch(filter, textSearchString(parsed.Text), "")
+}
+
+// entityFilter returns the filter shared by every search mode: the language,
+// every #hashtag present, and every @user mentioned or the author
+func entityFilter(parsed analysis.EntityQuery, lang string) bson.M {
+	filter := bson.M{}
+	if lang != "" {
+		filter["lang"] = lang
+	}
+	if len(parsed.Hashtags) > 0 {
+		filter["hashtags"] = bson.M{"$all": parsed.Hashtags}
+	}
+	if len(parsed.Mentions) > 0 {
+		mentionClauses := make([]bson.M, 0, len(parsed.Mentions))
+		for _, mention := range parsed.Mentions {
+			mentionClauses = append(mentionClauses, bson.M{"$or": []bson.M{
+				{"mentions": mention},
+				{"author": mention},
+			}})
+		}
+		filter["$and"] = mentionClauses
+	}
+	return filter
+}
+
+// withTextSearch returns a copy of the filter that also matches the search text
+// with the text index, or the filter itself when there is no text
+func withTextSearch(filter bson.M, searchText, lang string) bson.M {
+	if searchText == "" {
+		return filter
+	}
+
+	textQuery := bson.M{"$search": searchText}
+	if lang != "" {
+		textQuery["$language"] = analysis.MongoLanguage(lang)
+	}
+	textFilter := bson.M{"$text": textQuery}
+	for key, value := range filter {
+		textFilter[key] = value
+	}
+	return textFilter
+}
+
+// runSearch executes one page of a search in the given mode and returns the
+// ranked documents, along with the text pipeline that ran (if any). With
+// collapse, comments of the same post are grouped into one result; semantic
+// mode doesn't support it.
+func runSearch(ctx context.Context, cfg ranking.Config, mode string, textFilter, filter bson.M, text string, collapse bool, skip, limit int64) ([]rankedDocument, []bson.M, error) {
+	switch mode {
+	case searchModeSemantic:
+		documents, err := semanticSearch(ctx, filter, text, skip, limit)
+		return documents, nil, err
+	case searchModeHybrid:
+		return hybridSearch(ctx, cfg, textFilter, filter, text, collapse, skip, limit)
+	default:
+		// Build the ranking pipeline: text match, score boosts, then pagination
+		pipeline := cfg.Pipeline(textFilter, time.Now(), skip, limit)
+		if collapse {
+			pipeline = cfg.CollapsedPipeline(textFilter, time.Now(), skip, limit)
+		}
+		documents, err := aggregateRanked(ctx, pipeline)
+		return documents, pipeline, err
+	}
+}
+
+// newSearchResult converts a ranked document to a search result
+func newSearchResult(document rankedDocument, query string) models.SearchResult {
+	return models.SearchResult{
+		ID:          document.ID.Hex(),
+		ContentID:   document.ContentID,
+		ContentType: document.ContentType,
+		Title:       analysis.RedactEmails(document.Title),
+		Snippet:     createSnippet(document.Content, query),
+		Author:      document.Author,
+		CreatedAt:   document.CreatedAt,
+		UpdatedAt:   document.UpdatedAt,
+		Score:       document.Score,
+		NSFW:        document.NSFW,
+		Community:   document.Community,
+		CommunityID: document.CommunityID,
+		Parent:      parentRef(document.SearchIndex),
+		ThreadHits:  document.ThreadHits,
+	}
+}
+
+// textSearchString appends the normalized variants of the query's words so
+// accent-folded and Arabic-normalized documents match through normalized_text
+func textSearchString(text string) string {
+	var positive []string
+	for _, word := range strings.Fields(text) {
+		if !strings.HasPrefix(word, "-") {
+			positive = append(positive, word)
+		}
+	}
+
+	variants := analysis.NormalizedVariants(strings.Join(positive, " "))
+	if len(variants) == 0 {
+		return text
+	}
+	return text + " " + strings.Join(variants, " ")
+}
+
+// rankedDocument is a search_index document with the fields added by the ranking stages
+type rankedDocument struct {
+	models.SearchIndex `bson:",inline"`
+	TextScore          float64 `bson:"text_score"`
+	RecencyBoost       float64 `bson:"recency_boost"`
+	PopularityBoost    float64 `bson:"popularity_boost"`
+	ActivityBoost      float64 `bson:"activity_boost"`
+	GeoBoost           float64 `bson:"geo_boost"`
+	ThreadHits         int     `bson:"thread_hits"` // Hits grouped into this one by collapse=post
+	Similarity         float64 `bson:"-"`           // Embedding similarity in semantic and hybrid mode
+	TextRank           int     `bson:"-"`           // Rank among text hits in hybrid mode
+	SemanticRank       int     `bson:"-"`           // Rank among semantic hits in hybrid mode
+}
+
+// boosts returns the score boosts the ranking stages computed
+func (d rankedDocument) boosts() ranking.Boosts {
+	return ranking.Boosts{Recency: d.RecencyBoost, Popularity: d.PopularityBoost, Activity: d.ActivityBoost, Geo: d.GeoBoost}
+}
+
+// aggregateRanked runs a ranking pipeline over search_index
+func aggregateRanked(ctx context.Context, pipeline []bson.M) ([]rankedDocument, error) {
+	cursor, err := database.MongoDB.Collection("search_index").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var documents []rankedDocument
+	for cursor.Next(ctx) {
+		var document rankedDocument
+		if err := cursor.Decode(&document); err != nil {
+			log.Printf("Error decoding search result: %v", err)
+			continue
+		}
+		documents = append(documents, document)
+	}
+	return documents, cursor.Err()
 }
 
 // Index handles indexing new content
@@ -140,6 +560,26 @@ func (sc *SearchController) Index(c *gin.Context) {
 		return
 	}
 
+	// Content is public unless the caller restricts it
+	if len(indexRequest.Visibility) == 0 {
+		indexRequest.Visibility = []string{models.VisibilityPublic}
+	}
+	if err := validateVisibility(indexRequest.Visibility); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateLocation(indexRequest.Location); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A moderation state is only stored when supplied, so re-indexing never
+	// undoes a moderator's decision; new documents default to visible
+	if indexRequest.ModerationState != "" && !models.IsModerationState(indexRequest.ModerationState) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid moderation_state, expected visible, pending, hidden or removed"})
+		return
+	}
+
 	// Set indexed time
 	indexRequest.IndexedAt = time.Now()
 
@@ -148,22 +588,106 @@ func (sc *SearchController) Index(c *gin.Context) {
 		indexRequest.ID = primitive.NewObjectID()
 	}
 
+	// Supplied tags and autocomplete phrases feed public suggestions, so they
+	// never keep email addresses either
+	indexRequest.Tags = redactEmailList(indexRequest.Tags)
+	indexRequest.AutocompletePhrases = redactEmailList(indexRequest.AutocompletePhrases)
+
+	// User documents get people search fields and never keep email addresses;
+	// community documents get structured metrics
+	switch indexRequest.ContentType {
+	case models.User:
+		prepareUserDocument(&indexRequest)
+	case models.Community:
+		prepareCommunityDocument(&indexRequest)
+	}
+
+	// Extract #hashtags and @mentions as first-class entities
+	for i, hashtag := range indexRequest.Hashtags {
+		indexRequest.Hashtags[i] = strings.ToLower(strings.TrimPrefix(hashtag, "#"))
+	}
+	for i, mention := range indexRequest.Mentions {
+		indexRequest.Mentions[i] = strings.ToLower(strings.TrimPrefix(mention, "@"))
+	}
+	entityText := indexRequest.Title + "\n" + indexRequest.Content
+	indexRequest.Hashtags = analysis.MergeUnique(indexRequest.Hashtags, analysis.ExtractHashtags(entityText)...)
+	indexRequest.Mentions = analysis.MergeUnique(indexRequest.Mentions, analysis.ExtractMentions(entityText)...)
+
+	// Detect the language unless the caller supplied a supported one. The
+	// language field tells the text index which stemmer and stopwords to use.
+	if !analysis.IsSupportedLanguage(indexRequest.Lang) {
+		indexRequest.Lang = analysis.DetectLanguage(indexRequest.Title + "\n" + indexRequest.Content)
+	}
+	indexRequest.Language = ""
+	if indexRequest.Lang != "" {
+		indexRequest.Language = analysis.MongoLanguage(indexRequest.Lang)
+	}
+
+	// Store normalized variants of words so folded and Arabic-normalized queries match
+	indexRequest.NormalizedText = strings.Join(analysis.NormalizedVariants(
+		strings.Join(append([]string{indexRequest.Title, indexRequest.Content}, indexRequest.Tags...), "\n"),
+	), " ")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Count candidate words and phrases, with title occurrences weighted higher
+	candidates := analysis.CountCandidates(indexRequest.Lang,
+		analysis.WeightedText{Text: indexRequest.Title, Weight: 3},
+		analysis.WeightedText{Text: indexRequest.Content, Weight: 1},
+	)
+
 	// Extract key phrases for autocomplete if not provided
 	if len(indexRequest.AutocompletePhrases) == 0 {
-		indexRequest.AutocompletePhrases = extractKeyPhrases(indexRequest)
+		indexRequest.AutocompletePhrases = extractKeyPhrases(ctx, indexRequest, candidates)
 	}
 
+	// Embed the document for semantic search
+	embedDocument(ctx, &indexRequest)
+
 	// Set default popularity score if not provided
 	if indexRequest.PopularityScore == 0 {
 		indexRequest.PopularityScore = 1.0 // Default score
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
 	// Upsert the document
 	filter := bson.M{"content_id": indexRequest.ContentID, "content_type": indexRequest.ContentType}
 	update := bson.M{"$set": indexRequest}
+
+	// Fields left out of $set because they are empty would keep the values of the
+	// previous version, so they are unset instead
+	unset := bson.M{}
+	if indexRequest.EmbeddingModel == "" {
+		// Don't keep the embedding of the previous content when embedding failed
+		unset["embedding"], unset["embedding_model"] = "", ""
+	}
+	if len(indexRequest.Hashtags) == 0 {
+		unset["hashtags"] = ""
+	}
+	if len(indexRequest.Mentions) == 0 {
+		unset["mentions"] = ""
+	}
+	if indexRequest.Lang == "" {
+		unset["lang"], unset["language"] = "", ""
+	}
+	if indexRequest.NormalizedText == "" {
+		unset["normalized_text"] = ""
+	}
+	if indexRequest.ParentPostID == "" {
+		unset["parent_post_id"] = ""
+	}
+	if indexRequest.CommunityID == "" {
+		unset["community_id"] = ""
+	}
+	if indexRequest.Location == nil {
+		unset["location"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if indexRequest.ModerationState == "" {
+		update["$setOnInsert"] = bson.M{"moderation_state": models.ModerationVisible}
+	}
 	opts := options.Update().SetUpsert(true)
 
 	result, err := database.MongoDB.Collection("search_index").UpdateOne(ctx, filter, update, opts)
@@ -176,6 +700,32 @@ func (sc *SearchController) Index(c *gin.Context) {
 	// Create text index on collection if it doesn't exist
 	ensureTextIndex(ctx)
 
+	// Re-indexing may restrict or hide the content, so drop cached results that include it
+	if result.MatchedCount > 0 {
+		invalidateContentCache(ctx, []string{indexRequest.ContentID})
+	}
+
+	// New public, visible content counts its tags and hashtags towards trending
+	// activity and feeds autocomplete; restricted content only triggers alerts
+	if result.UpsertedCount > 0 && indexRequest.IsListed() {
+		activity := append([]string{}, indexRequest.Tags...)
+		for _, hashtag := range indexRequest.Hashtags {
+			activity = append(activity, "#"+hashtag)
+		}
+		recordTrendingTerms(activity, string(indexRequest.ContentType))
+		feedSuggestionsAsync(documentSuggestions(indexRequest), string(indexRequest.ContentType))
+	}
+	// Content that was re-indexed as restricted or hidden leaves autocomplete
+	if result.MatchedCount > 0 && !indexRequest.IsListed() {
+		retractSuggestionsAsync([]models.SearchIndex{indexRequest})
+	}
+	if result.UpsertedCount > 0 {
+		updateCorpusStatsAsync(candidates)
+		if indexRequest.IsVisible() {
+			percolateAlertsAsync(indexRequest)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":     "Content indexed successfully",
 		"upserted_id": indexRequest.ID.Hex(),
@@ -185,76 +735,39 @@ func (sc *SearchController) Index(c *gin.Context) {
 	})
 }
 
-// extractKeyPhrases extracts important phrases from content for autocomplete
-func extractKeyPhrases(doc models.SearchIndex) []string {
-	phrases := make(map[string]bool)
+// extractKeyPhrases extracts important phrases from content for autocomplete:
+// the title, tags and hashtags, then the best scoring words and collocations
+func extractKeyPhrases(ctx context.Context, doc models.SearchIndex, candidates map[string]float64) []string {
+	var phrases []string
 
 	// Add title if available
 	if doc.Title != "" {
-		phrases[doc.Title] = true
-
-		// Add title words that are significant (length > 3)
-		words := strings.Fields(doc.Title)
-		for _, word := range words {
-			if len(word) > 3 {
-				phrases[word] = true
-			}
-		}
+		phrases = append(phrases, doc.Title)
 	}
 
 	// Add all tags
-	for _, tag := range doc.Tags {
-		phrases[tag] = true
-	}
-
-	// Extract significant phrases from content
-	if doc.Content != "" {
-		// Simple approach: split by spaces and punctuation
-		words := strings.FieldsFunc(doc.Content, func(r rune) bool {
-			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
-		})
+	phrases = analysis.MergeUnique(phrases, doc.Tags...)
 
-		// Add significant words (length > 3)
-		for _, word := range words {
-			if len(word) > 3 {
-				phrases[word] = true
-			}
-		}
-
-		// TODO: More sophisticated phrase extraction could be added here
-		// For example, extracting n-grams or using NLP techniques
+	// Add hashtags with their # so they complete as hashtags
+	for _, hashtag := range doc.Hashtags {
+		phrases = analysis.MergeUnique(phrases, "#"+hashtag)
 	}
 
-	// Convert map to slice
-	result := make([]string, 0, len(phrases))
-	for phrase := range phrases {
-		result = append(result, phrase)
+	// Score words, bigrams and trigrams against corpus statistics
+	stats, err := loadCorpusStats(ctx, candidates)
+	if err != nil {
+		log.Printf("Error loading phrase statistics: %v", err)
 	}
+	phrases = analysis.MergeUnique(phrases, analysis.ScorePhrases(candidates, stats, maxPhrasesPerDoc)...)
 
-	return result
+	return phrases
 }
 
-// ensureTextIndex ensures that text indexes exist on the necessary fields
+// ensureTextIndex creates the text index if it is missing, e.g. after the
+// collection was dropped; changed definitions are only migrated at startup
 func ensureTextIndex(ctx context.Context) {
-	// Define the text index model
-	indexModel := mongo.IndexModel{
-		Keys: bson.D{
-			{Key: "title", Value: "text"},
-			{Key: "content", Value: "text"},
-			{Key: "tags", Value: "text"},
-			{Key: "autocomplete_phrases", Value: "text"},
-		},
-		Options: options.Index().SetWeights(bson.D{
-			{Key: "title", Value: 10},
-			{Key: "tags", Value: 5},
-			{Key: "autocomplete_phrases", Value: 3},
-			{Key: "content", Value: 1},
-		}).SetName("text_search_index"),
-	}
-
-	// Create the index
-	_, err := database.MongoDB.Collection("search_index").Indexes().CreateOne(ctx, indexModel)
-	if err != nil {
+	// Use the same definition as InitIndexes so the weights never diverge
+	if err := database.EnsureTextIndex(ctx); err != nil {
 		log.Printf("Warning: Failed to create text index: %v", err)
 	}
 }
@@ -277,6 +790,12 @@ func (sc *SearchController) Delete(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Remember what the content fed into autocomplete before it is gone
+	documents, err := suggestionSources(ctx, filter)
+	if err != nil {
+		log.Printf("Error loading deleted content suggestions: %v", err)
+	}
+
 	result, err := database.MongoDB.Collection("search_index").DeleteMany(ctx, filter)
 	if err != nil {
 		log.Printf("Delete error: %v", err)
@@ -284,6 +803,10 @@ func (sc *SearchController) Delete(c *gin.Context) {
 		return
 	}
 
+	// Drop cached results and suggestions that still include the content
+	invalidateContentCache(ctx, []string{contentID})
+	retractSuggestionsAsync(documents)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Content removed from index successfully",
 		"deleted_count": result.DeletedCount,
@@ -292,6 +815,8 @@ func (sc *SearchController) Delete(c *gin.Context) {
 
 // Recommend provides real-time search suggestions as the user types
 func (sc *SearchController) Recommend(c *gin.Context) {
+	start := time.Now()
+
 	// Get the user input (prefix)
 	prefix := c.Query("prefix")
 	if prefix == "" {
@@ -302,110 +827,55 @@ func (sc *SearchController) Recommend(c *gin.Context) {
 	// Get content type if specified (optional filter)
 	contentType := c.Query("type")
 
-	// Try to get cached suggestions
-	cacheKey := fmt.Sprintf("suggestions:%s:%s", prefix, contentType)
-	cachedSuggestions, err := getCachedResults(cacheKey)
-	if err == nil {
-		c.JSON(http.StatusOK, cachedSuggestions)
-		return
-	}
-
-	// Create the context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	// Prepare the filter for MongoDB
-	filter := bson.M{
-		"$or": []bson.M{
-			// Search for title starting with the prefix (case insensitive)
-			{"title": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix), "$options": "i"}},
-			// Search for content words starting with the prefix
-			{"content": bson.M{"$regex": "\\b" + regexp.QuoteMeta(prefix), "$options": "i"}},
-			// Search in tags
-			{"tags": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix), "$options": "i"}},
-		},
-	}
-
-	// Add content type filter if specified
-	if contentType != "" {
-		filter["content_type"] = contentType
-	}
-
-	// Set options for MongoDB query
-	findOptions := options.Find()
-	findOptions.SetLimit(int64(maxSuggestions))
+	// Phrases only blocked or muted users wrote are hidden, so callers with a
+	// block list get their own cache entry
+	viewer := resolveExclusions(ctx, c)
 
-	// Execute the query
-	cursor, err := database.MongoDB.Collection("search_index").Find(ctx, filter, findOptions)
-	if err != nil {
-		log.Printf("Suggest error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch suggestions"})
-		return
+	// Try to get cached suggestions
+	cacheKey := fmt.Sprintf("suggestions:%s:%s:%s", viewer.ExclusionScope(), normalizeQuery(prefix), contentType)
+	event := models.SearchEvent{
+		Endpoint: "recommend",
+		Query:    prefix,
+		Filters:  map[string]any{"type": contentType},
 	}
-	defer cursor.Close(ctx)
-
-	// Process suggestions
-	var suggestions []string
-	termMap := make(map[string]bool) // To avoid duplicates
-
-	// Process the cursor results
-	for cursor.Next(ctx) {
-		var document models.SearchIndex
-		if err := cursor.Decode(&document); err != nil {
-			log.Printf("Error decoding suggestion result: %v", err)
-			continue
-		}
-
-		// Add title suggestions if title starts with prefix
-		if document.Title != "" && strings.HasPrefix(strings.ToLower(document.Title), strings.ToLower(prefix)) {
-			if !termMap[document.Title] {
-				suggestions = append(suggestions, document.Title)
-				termMap[document.Title] = true
-			}
-		}
-
-		// Extract words from content that start with the prefix
-		if document.Content != "" {
-			words := strings.Fields(document.Content)
-			for _, word := range words {
-				if len(word) > 2 && strings.HasPrefix(strings.ToLower(word), strings.ToLower(prefix)) {
-					if !termMap[word] {
-						suggestions = append(suggestions, word)
-						termMap[word] = true
-					}
-				}
-			}
+	responseData, err := getCachedResults(cacheKey)
+	event.CacheHit = err == nil
+
+	// Suggestions come from the dedicated store, so keystroke lookups hit one index
+	if !event.CacheHit {
+		suggestions, err := lookupSuggestions(ctx, prefix, contentType, maxSuggestions, viewer.Excluded)
+		if err != nil {
+			log.Printf("Suggest error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch suggestions"})
+			return
 		}
 
-		// Add matching tags
-		for _, tag := range document.Tags {
-			if strings.HasPrefix(strings.ToLower(tag), strings.ToLower(prefix)) {
-				if !termMap[tag] {
-					suggestions = append(suggestions, tag)
-					termMap[tag] = true
-				}
-			}
+		// Prepare response
+		responseData = gin.H{
+			"suggestions": suggestions,
+			"prefix":      prefix,
 		}
 
-		// Limit to max suggestions
-		if len(suggestions) >= maxSuggestions {
-			break
-		}
+		// Cache the global suggestions
+		cacheResults(cacheKey, responseData)
 	}
 
-	// Prepare response
-	responseData := gin.H{
-		"suggestions": suggestions,
-		"prefix":      prefix,
-	}
+	responseData["prefix"] = prefix
 
-	// Cache the results for 5 minutes
-	cacheResults(cacheKey, responseData)
+	// Put the user's own recent matches first, after caching so they are never shared
+	if user, ok := middleware.CurrentUser(c); ok {
+		personalizeSuggestions(ctx, user.ID, prefix, responseData)
+	}
 
-	c.JSON(http.StatusOK, responseData)
+	event.ResultCount = countOf(responseData["suggestions"])
+	respondWithSearchEvent(c, responseData, event, start)
 }
 
-// TrendingSearches returns the most popular search terms
+// TrendingSearches returns the terms people are searching for and tagging most
+// within a sliding time window
 func (sc *SearchController) TrendingSearches(c *gin.Context) {
 	// Get content type if specified (optional filter)
 	contentType := c.Query("type")
@@ -419,11 +889,11 @@ func (sc *SearchController) TrendingSearches(c *gin.Context) {
 		limit = 50
 	}
 
-	// Try to get cached trending terms
-	cacheKey := fmt.Sprintf("trending:%s:%d", contentType, limit)
-	cachedTrending, err := getCachedResults(cacheKey)
-	if err == nil {
-		c.JSON(http.StatusOK, cachedTrending)
+	// Get the time window (default to 24h)
+	windowName := c.DefaultQuery("window", defaultTrendingWindow)
+	window, ok := trendingWindows[windowName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window, expected 1h, 24h or 7d"})
 		return
 	}
 
@@ -431,83 +901,41 @@ func (sc *SearchController) TrendingSearches(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Prepare the aggregation pipeline
-	pipeline := []bson.M{
-		{
-			"$project": bson.M{
-				"phrases":          "$autocomplete_phrases",
-				"content_type":     1,
-				"popularity_score": 1,
-			},
-		},
-	}
+	// Blocked and muted users are filtered out per caller
+	viewer := resolveExclusions(ctx, c)
 
-	// Add content type filter if specified
-	if contentType != "" {
-		pipeline = append(pipeline, bson.M{
-			"$match": bson.M{
-				"content_type": contentType,
-			},
-		})
+	// Try to get cached trending terms
+	cacheKey := fmt.Sprintf("trending:%s:%s:%s:%d", viewer.ExclusionScope(), contentType, windowName, limit)
+	cachedTrending, err := getCachedResults(cacheKey)
+	if err == nil {
+		c.JSON(http.StatusOK, cachedTrending)
+		return
 	}
 
-	// Unwind the phrases array to get individual phrases
-	pipeline = append(pipeline, bson.M{
-		"$unwind": "$phrases",
-	})
-
-	// Group by phrase and sum popularity scores
-	pipeline = append(pipeline, bson.M{
-		"$group": bson.M{
-			"_id":   "$phrases",
-			"score": bson.M{"$sum": "$popularity_score"},
-			"count": bson.M{"$sum": 1},
-		},
-	})
-
-	// Sort by score (descending)
-	pipeline = append(pipeline, bson.M{
-		"$sort": bson.M{
-			"score": -1,
-			"count": -1,
-		},
-	})
-
-	// Limit the results
-	pipeline = append(pipeline, bson.M{
-		"$limit": limit,
-	})
-
-	// Execute the aggregation
-	cursor, err := database.MongoDB.Collection("search_index").Aggregate(ctx, pipeline)
+	// Rank real query and hashtag activity, falling back to indexed phrases without Redis
+	var trendingTerms []models.TrendingTerm
+	source := "activity"
+	if RedisClient != nil {
+		trendingTerms, err = queryTrendingTerms(ctx, contentType, window, limit, viewer.Excluded)
+	} else {
+		source = "index"
+		trendingTerms, err = indexTrendingTerms(ctx, contentType, limit, viewer.Excluded)
+	}
 	if err != nil {
 		log.Printf("Trending error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trending terms"})
 		return
 	}
-	defer cursor.Close(ctx)
-
-	// Process the results
-	type TrendingTerm struct {
-		Term  string  `bson:"_id" json:"term"`
-		Score float64 `bson:"score" json:"score"`
-		Count int     `bson:"count" json:"count"`
-	}
-
-	var trendingTerms []TrendingTerm
-	if err := cursor.All(ctx, &trendingTerms); err != nil {
-		log.Printf("Error processing trending terms: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process trending terms"})
-		return
-	}
 
 	// Prepare the response
 	responseData := gin.H{
 		"trending": trendingTerms,
 		"count":    len(trendingTerms),
+		"window":   windowName,
+		"source":   source,
 	}
 
-	// Cache the results for 1 hour (trending changes less frequently)
+	// Cache the results (trending changes less frequently)
 	cacheResults(cacheKey, responseData)
 
 	c.JSON(http.StatusOK, responseData)
@@ -536,6 +964,7 @@ func getPaginationParams(c *gin.Context) (int, int) {
 // createSnippet generates a short snippet from the content
 func createSnippet(content string, query string) string {
 	// Simple implementation - in a real-world scenario, this would be more sophisticated
+	content = analysis.RedactEmails(content)
 	maxLength := 150
 	if len(content) <= maxLength {
 		return content
@@ -543,6 +972,24 @@ func createSnippet(content string, query string) string {
 	return content[:maxLength] + "..."
 }
 
+// extJSON renders a Mongo query value as relaxed extended JSON for explain output
+func extJSON(value any) json.RawMessage {
+	data, err := bson.MarshalExtJSON(bson.M{"value": value}, false, false)
+	if err != nil {
+		log.Printf("Error marshaling explain output: %v", err)
+		return nil
+	}
+
+	var wrapper struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		log.Printf("Error unmarshaling explain output: %v", err)
+		return nil
+	}
+	return wrapper.Value
+}
+
 // getCachedResults attempts to retrieve search results from Redis cache
 func getCachedResults(key string) (gin.H, error) {
 	if RedisClient == nil {
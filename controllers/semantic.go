@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"circleconnect-search/database"
+	"circleconnect-search/embedding"
+	"circleconnect-search/models"
+)
+
+// Embedder computes document and query embeddings for semantic search. main
+// replaces the default with the provider configured in the environment.
+var Embedder embedding.Embedder = embedding.Default()
+
+// Search modes
+const (
+	searchModeText     = "text"
+	searchModeSemantic = "semantic"
+	searchModeHybrid   = "hybrid"
+)
+
+// Semantic search tuning
+const (
+	maxEmbeddingTextLength = 2000  // Characters of title, tags and content embedded per document
+	maxSemanticCandidates  = 50000 // Documents compared per query by the brute-force scan
+	minSemanticSimilarity  = 0.2   // Weaker matches are noise rather than results
+)
+
+// embeddingText is the part of a document that is embedded
+func embeddingText(doc models.SearchIndex) string {
+	text := strings.Join(append([]string{doc.Title}, append(doc.Tags, doc.Content)...), "\n")
+	if runes := []rune(text); len(runes) > maxEmbeddingTextLength {
+		text = string(runes[:maxEmbeddingTextLength])
+	}
+	return text
+}
+
+// embedDocument sets the document's embedding with the current embedder. On
+// failure the document is indexed without one and only found by text search.
+func embedDocument(ctx context.Context, doc *models.SearchIndex) {
+	vectors, err := Embedder.Embed(ctx, []string{embeddingText(*doc)})
+	if err != nil {
+		log.Printf("Error embedding document %s: %v", doc.ContentID, err)
+		doc.Embedding, doc.EmbeddingModel = nil, ""
+		return
+	}
+	doc.Embedding, doc.EmbeddingModel = vectors[0], Embedder.Model()
+}
+
+// semanticSearch embeds the query and ranks the documents matching the filter by
+// cosine similarity with a brute-force scan over their stored embeddings. Only
+// documents embedded with the current model are compared, newest first up to
+// maxSemanticCandidates.
+func semanticSearch(ctx context.Context, filter bson.M, text string, skip, limit int64) ([]rankedDocument, error) {
+	vectors, err := Embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	queryVector := vectors[0]
+
+	semanticFilter := bson.M{"embedding_model": Embedder.Model()}
+	for key, value := range filter {
+		semanticFilter[key] = value
+	}
+
+	// One extra candidate tells whether the scan was truncated
+	cursor, err := database.MongoDB.Collection("search_index").Find(ctx, semanticFilter, options.Find().
+		SetProjection(bson.M{"embedding": 1}).
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(maxSemanticCandidates+1))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	type hit struct {
+		id         primitive.ObjectID
+		similarity float64
+	}
+	var hits []hit
+	scanned := 0
+	for cursor.Next(ctx) {
+		if scanned++; scanned > maxSemanticCandidates {
+			log.Printf("Semantic search truncated: compared only the newest %d candidates", maxSemanticCandidates)
+			break
+		}
+		var candidate struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			Embedding []float32          `bson:"embedding"`
+		}
+		if err := cursor.Decode(&candidate); err != nil {
+			continue
+		}
+		if similarity := embedding.Cosine(queryVector, candidate.Embedding); similarity >= minSemanticSimilarity {
+			hits = append(hits, hit{id: candidate.ID, similarity: similarity})
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].similarity != hits[j].similarity {
+			return hits[i].similarity > hits[j].similarity
+		}
+		return hits[i].id.Hex() < hits[j].id.Hex()
+	})
+	if skip >= int64(len(hits)) {
+		return nil, nil
+	}
+	hits = hits[skip:]
+	if int64(len(hits)) > limit {
+		hits = hits[:limit]
+	}
+
+	// Load the full documents of the page, then restore the similarity order
+	ids := make([]primitive.ObjectID, len(hits))
+	for i, h := range hits {
+		ids[i] = h.id
+	}
+	docCursor, err := database.MongoDB.Collection("search_index").Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"embedding": 0}))
+	if err != nil {
+		return nil, err
+	}
+	defer docCursor.Close(ctx)
+
+	byID := make(map[primitive.ObjectID]models.SearchIndex, len(hits))
+	for docCursor.Next(ctx) {
+		var document models.SearchIndex
+		if err := docCursor.Decode(&document); err != nil {
+			log.Printf("Error decoding semantic result: %v", err)
+			continue
+		}
+		byID[document.ID] = document
+	}
+
+	documents := make([]rankedDocument, 0, len(hits))
+	for _, h := range hits {
+		if document, ok := byID[h.id]; ok {
+			document.Score = h.similarity
+			documents = append(documents, rankedDocument{SearchIndex: document, Similarity: h.similarity})
+		}
+	}
+	return documents, docCursor.Err()
+}
+
+// RebuildEmbeddings embeds every document that has no embedding from the current
+// model, e.g. after enabling semantic search or switching embedding providers
+func (sc *SearchController) RebuildEmbeddings(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	model := Embedder.Model()
+	collection := database.MongoDB.Collection("search_index")
+	cursor, err := collection.Find(ctx, bson.M{"embedding_model": bson.M{"$ne": model}},
+		options.Find().SetProjection(bson.M{"title": 1, "tags": 1, "content": 1, "content_id": 1}))
+	if err != nil {
+		log.Printf("Error reading search index: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read search index"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	embedded, failed := 0, 0
+	for cursor.Next(ctx) {
+		var document models.SearchIndex
+		if err := cursor.Decode(&document); err != nil {
+			failed++
+			continue
+		}
+
+		embedDocument(ctx, &document)
+		if document.EmbeddingModel == "" {
+			failed++
+			continue
+		}
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": document.ID}, bson.M{
+			"$set": bson.M{"embedding": document.Embedding, "embedding_model": document.EmbeddingModel},
+		})
+		if err != nil {
+			log.Printf("Error storing embedding: %v", err)
+			failed++
+			continue
+		}
+		embedded++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("Embeddings rebuilt with %s", model),
+		"embedded": embedded,
+		"failed":   failed,
+	})
+}
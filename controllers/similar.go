@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/database"
+	"circleconnect-search/models"
+)
+
+// Similar content tuning
+const (
+	maxSimilarTerms  = 12  // Distinctive terms of the source used in the query
+	similarTagWeight = 5.0 // Similarity added per shared tag or hashtag, like the tags text weight
+)
+
+// contentTypes are the valid values of the types parameter
+var contentTypes = map[models.ContentType]bool{
+	models.Post:      true,
+	models.Community: true,
+	models.User:      true,
+	models.Comment:   true,
+}
+
+// distinctiveTerms returns the document's single words ranked by TF-IDF against
+// the corpus statistics, with title occurrences weighted higher
+func distinctiveTerms(ctx context.Context, doc models.SearchIndex) []string {
+	candidates := analysis.CountCandidates(doc.Lang,
+		analysis.WeightedText{Text: doc.Title, Weight: 3},
+		analysis.WeightedText{Text: strings.Join(doc.Tags, ". "), Weight: 2},
+		analysis.WeightedText{Text: doc.Content, Weight: 1},
+	)
+	for candidate := range candidates {
+		if strings.Contains(candidate, " ") {
+			delete(candidates, candidate)
+		}
+	}
+
+	stats, err := loadCorpusStats(ctx, candidates)
+	if err != nil {
+		log.Printf("Error loading phrase statistics: %v", err)
+	}
+	return analysis.ScorePhrases(candidates, stats, maxSimilarTerms)
+}
+
+// Similar returns content like the given document ("more like this"), ranked by
+// shared distinctive terms, tags and hashtags
+func (sc *SearchController) Similar(c *gin.Context) {
+	contentType := models.ContentType(c.Param("type"))
+	contentID := c.Param("id")
+	if !contentTypes[contentType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid content type"})
+		return
+	}
+
+	// Result content types default to the source's type
+	types := []string{string(contentType)}
+	if typesParam := c.Query("types"); typesParam != "" {
+		types = nil
+		for _, t := range strings.Split(typesParam, ",") {
+			t = strings.TrimSpace(t)
+			if !contentTypes[models.ContentType(t)] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid types, expected post, community, user or comment"})
+				return
+			}
+			types = analysis.MergeUnique(types, t)
+		}
+		sort.Strings(types)
+	}
+
+	excludeAuthor := c.Query("exclude_author") == "true"
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+	if err != nil || limit < 1 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The caller must be allowed to see the source, and sees only results they may see
+	viewer := resolveViewer(ctx, c)
+
+	cacheKey := fmt.Sprintf("similar:%s:%s:%s:%s:%t:%d", viewer.CacheScope(), contentType, contentID,
+		strings.Join(types, ","), excludeAuthor, limit)
+	if cachedResults, err := getCachedResults(cacheKey); err == nil {
+		enrichCachedResponse(ctx, cachedResults)
+		c.JSON(http.StatusOK, cachedResults)
+		return
+	}
+
+	// Load the source document
+	sourceFilter := bson.M{"content_type": contentType, "content_id": contentID}
+	andFilter(sourceFilter, viewer.Filter())
+	andFilter(sourceFilter, visibleFilter())
+
+	var source models.SearchIndex
+	if err := database.MongoDB.Collection("search_index").FindOne(ctx, sourceFilter).Decode(&source); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Content not found"})
+		return
+	}
+
+	terms := distinctiveTerms(ctx, source)
+	tags := append([]string{}, source.Tags...)
+	hashtags := append([]string{}, source.Hashtags...)
+
+	responseData := gin.H{
+		"source":  gin.H{"content_id": source.ContentID, "content_type": source.ContentType},
+		"terms":   terms,
+		"results": []models.SearchResult{},
+		"total":   0,
+	}
+	if len(terms) == 0 && len(tags) == 0 && len(hashtags) == 0 {
+		c.JSON(http.StatusOK, responseData)
+		return
+	}
+
+	// Match on the distinctive terms, or on shared tags when the source has no text
+	filter := bson.M{
+		"_id":          bson.M{"$ne": source.ID},
+		"content_type": bson.M{"$in": types},
+		"nsfw":         bson.M{"$ne": true},
+	}
+	if len(terms) > 0 {
+		filter["$text"] = bson.M{"$search": strings.Join(terms, " ")}
+	} else {
+		andFilter(filter, bson.M{"$or": []bson.M{
+			{"tags": bson.M{"$in": tags}},
+			{"hashtags": bson.M{"$in": hashtags}},
+		}})
+	}
+	if excludeAuthor && source.Author != "" {
+		filter["author"] = bson.M{"$ne": source.Author}
+	}
+	andFilter(filter, viewer.Filter())
+	andFilter(filter, visibleFilter())
+
+	textScore := any(0)
+	if len(terms) > 0 {
+		textScore = bson.M{"$meta": "textScore"}
+	}
+	sharedCount := func(field string, values []string) bson.M {
+		return bson.M{"$size": bson.M{"$setIntersection": []any{bson.M{"$ifNull": []any{"$" + field, []string{}}}, values}}}
+	}
+
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$addFields": bson.M{
+			"text_score":      textScore,
+			"shared_tags":     sharedCount("tags", tags),
+			"shared_hashtags": sharedCount("hashtags", hashtags),
+		}},
+		{"$addFields": bson.M{
+			"score": bson.M{"$add": []any{
+				"$text_score",
+				bson.M{"$multiply": []any{similarTagWeight, bson.M{"$add": []any{"$shared_tags", "$shared_hashtags"}}}},
+			}},
+		}},
+		{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}},
+		{"$limit": limit},
+		{"$project": bson.M{"embedding": 0}},
+	}
+
+	cursor, err := database.MongoDB.Collection("search_index").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("Similar content error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find similar content"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.SearchResult{}
+	for cursor.Next(ctx) {
+		var document models.SearchIndex
+		if err := cursor.Decode(&document); err != nil {
+			log.Printf("Error decoding similar content: %v", err)
+			continue
+		}
+
+		results = append(results, newSearchResult(rankedDocument{SearchIndex: document}, strings.Join(terms, " ")))
+	}
+
+	attachParents(ctx, viewer, results)
+
+	responseData["results"] = results
+	responseData["total"] = len(results)
+
+	// Cache results, including the source so its moderation also drops the entry
+	cacheResults(cacheKey, responseData)
+	trackCachedResults(cacheKey, append(results, models.SearchResult{ContentID: source.ContentID}))
+	enrichResults(ctx, results)
+
+	c.JSON(http.StatusOK, responseData)
+}
@@ -0,0 +1,410 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"circleconnect-search/database"
+	"circleconnect-search/models"
+)
+
+// Suggestion store limits
+const (
+	maxSuggestionPrefix  = 20 // Longest prefix stored as an edge n-gram
+	maxSuggestionLength  = 80 // Longer phrases are not useful completions
+	maxSuggestionsPerDoc = 50 // Cap on entries fed from a single document
+	maxSuggestionAuthors = 20 // Phrases used by this many authors are never hidden by a block list
+)
+
+// suggestionEntry is a phrase to add to the suggestion store
+type suggestionEntry struct {
+	Phrase string
+	Weight float64
+	Source string
+	Author string // Author of the document the phrase came from, if any
+}
+
+// edgeNGrams returns the prefixes of the phrase starting at every word, so
+// "remote work" completes from both "rem" and "wor". Leading # and @ are also
+// dropped so "go" completes "#golang".
+func edgeNGrams(normalized string) []string {
+	var grams []string
+	seen := make(map[string]bool)
+	add := func(tail []rune) {
+		for n := 1; n <= len(tail) && n <= maxSuggestionPrefix; n++ {
+			gram := string(tail[:n])
+			if !seen[gram] {
+				seen[gram] = true
+				grams = append(grams, gram)
+			}
+		}
+	}
+
+	words := strings.Fields(normalized)
+	for i := range words {
+		tail := []rune(strings.Join(words[i:], " "))
+		add(tail)
+		if len(tail) > 1 && (tail[0] == '#' || tail[0] == '@') {
+			add(tail[1:])
+		}
+	}
+	return grams
+}
+
+// documentSuggestions returns the suggestion entries an indexed document feeds,
+// weighted by source and by the document's popularity
+func documentSuggestions(doc models.SearchIndex) []suggestionEntry {
+	popularity := doc.PopularityScore
+	if popularity < 1 {
+		popularity = 1
+	}
+
+	// A user's profile is attributed to the user so blocking hides their name
+	author := doc.Author
+	if doc.ContentType == models.User {
+		author = doc.ContentID
+	}
+
+	var entries []suggestionEntry
+	if doc.Title != "" {
+		entries = append(entries, suggestionEntry{Phrase: doc.Title, Weight: 3 * popularity, Source: "title", Author: author})
+	}
+	for _, tag := range doc.Tags {
+		entries = append(entries, suggestionEntry{Phrase: tag, Weight: 2 * popularity, Source: "tag", Author: author})
+	}
+	for _, hashtag := range doc.Hashtags {
+		entries = append(entries, suggestionEntry{Phrase: "#" + hashtag, Weight: 2 * popularity, Source: "hashtag", Author: author})
+	}
+	for _, phrase := range doc.AutocompletePhrases {
+		entries = append(entries, suggestionEntry{Phrase: phrase, Weight: popularity, Source: "phrase", Author: author})
+	}
+
+	if len(entries) > maxSuggestionsPerDoc {
+		entries = entries[:maxSuggestionsPerDoc]
+	}
+	return entries
+}
+
+// feedSuggestions upserts entries into the suggestion store for the "all" scope
+// and the content type. Entries are deduplicated case-insensitively: the first
+// display form wins and weights accumulate. Up to maxSuggestionAuthors authors
+// are kept per phrase so block lists can hide phrases only their users wrote.
+func feedSuggestions(ctx context.Context, entries []suggestionEntry, contentType string) error {
+	type merged struct {
+		phrase  string
+		weight  float64
+		sources []string
+		authors []string
+	}
+
+	byPhrase := make(map[string]*merged)
+	var order []string
+	for _, entry := range entries {
+		normalized := normalizeQuery(entry.Phrase)
+		if len([]rune(normalized)) < 2 || len(normalized) > maxSuggestionLength {
+			continue
+		}
+
+		m, ok := byPhrase[normalized]
+		if !ok {
+			m = &merged{phrase: strings.Join(strings.Fields(entry.Phrase), " ")}
+			byPhrase[normalized] = m
+			order = append(order, normalized)
+		}
+		m.weight += entry.Weight
+		if !containsString(m.sources, entry.Source) {
+			m.sources = append(m.sources, entry.Source)
+		}
+		if entry.Author != "" && !containsString(m.authors, entry.Author) {
+			m.authors = append(m.authors, entry.Author)
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var writes []mongo.WriteModel
+	for _, scope := range trendingScopes(contentType) {
+		for _, normalized := range order {
+			m := byPhrase[normalized]
+			id := scope + ":" + normalized
+			onInsert := bson.M{
+				"phrase":     m.phrase,
+				"normalized": normalized,
+				"scope":      scope,
+				"prefixes":   edgeNGrams(normalized),
+			}
+			if len(m.authors) > 0 {
+				onInsert["authors"] = m.authors
+			}
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": id}).
+				SetUpdate(bson.M{
+					"$setOnInsert": onInsert,
+					"$inc":         bson.M{"weight": m.weight},
+					"$addToSet":    bson.M{"sources": bson.M{"$each": m.sources}},
+					"$set":         bson.M{"updated_at": now},
+				}).
+				SetUpsert(true))
+
+			// Existing phrases gain authors until the cap; new ones got them on insert
+			if len(m.authors) > 0 {
+				writes = append(writes, mongo.NewUpdateOneModel().
+					SetFilter(bson.M{"_id": id, fmt.Sprintf("authors.%d", maxSuggestionAuthors-1): bson.M{"$exists": false}}).
+					SetUpdate(bson.M{"$addToSet": bson.M{"authors": bson.M{"$each": m.authors}}}))
+			}
+		}
+	}
+
+	_, err := database.MongoDB.Collection("suggestions").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// feedSuggestionsAsync feeds the suggestion store in the background
+func feedSuggestionsAsync(entries []suggestionEntry, contentType string) {
+	if database.MongoDB == nil || len(entries) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := feedSuggestions(ctx, entries, contentType); err != nil {
+			log.Printf("Error feeding suggestions: %v", err)
+		}
+	}()
+}
+
+// retractSuggestions removes what documents fed into the suggestion store once
+// they may no longer be listed: phrases only their author wrote are deleted, and
+// phrases other authors wrote too forget the author. Searched phrases are kept.
+func retractSuggestions(ctx context.Context, documents []models.SearchIndex) error {
+	var writes []mongo.WriteModel
+	for _, document := range documents {
+		entries := documentSuggestions(document)
+		if len(entries) == 0 || entries[0].Author == "" {
+			continue
+		}
+		author := entries[0].Author
+
+		var ids []string
+		for _, scope := range trendingScopes(string(document.ContentType)) {
+			for _, entry := range entries {
+				ids = append(ids, scope+":"+normalizeQuery(entry.Phrase))
+			}
+		}
+		writes = append(writes,
+			mongo.NewDeleteManyModel().SetFilter(bson.M{
+				"_id":     bson.M{"$in": ids},
+				"authors": []string{author},
+				"sources": bson.M{"$ne": "query"},
+			}),
+			mongo.NewUpdateManyModel().
+				SetFilter(bson.M{"_id": bson.M{"$in": ids}, "authors": author}).
+				SetUpdate(bson.M{"$pull": bson.M{"authors": author}}),
+		)
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	_, err := database.MongoDB.Collection("suggestions").BulkWrite(ctx, writes)
+	return err
+}
+
+// retractSuggestionsAsync retracts the documents' suggestions in the background
+func retractSuggestionsAsync(documents []models.SearchIndex) {
+	if database.MongoDB == nil || len(documents) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := retractSuggestions(ctx, documents); err != nil {
+			log.Printf("Error retracting suggestions: %v", err)
+		}
+	}()
+}
+
+// suggestionSources loads the fields documents feed suggestions from
+func suggestionSources(ctx context.Context, filter bson.M) ([]models.SearchIndex, error) {
+	cursor, err := database.MongoDB.Collection("search_index").Find(ctx, filter,
+		options.Find().SetProjection(bson.M{
+			"content_id": 1, "content_type": 1, "author": 1, "title": 1, "tags": 1,
+			"hashtags": 1, "autocomplete_phrases": 1, "popularity_score": 1,
+		}))
+	if err != nil {
+		return nil, err
+	}
+	var documents []models.SearchIndex
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// lookupSuggestions returns the highest weighted phrases completing the prefix.
+// The query is a single equality match on prefixes backed by scope_prefix_weight_index.
+// Phrases that only excluded users wrote are left out.
+func lookupSuggestions(ctx context.Context, prefix string, contentType string, limit int, excluded []string) ([]string, error) {
+	normalized := normalizeQuery(prefix)
+	scope := "all"
+	if contentType != "" {
+		scope = contentType
+	}
+
+	// Prefixes are stored up to maxSuggestionPrefix runes; longer input is
+	// looked up by its stored prefix and checked here
+	key := normalized
+	fetch := limit
+	if runes := []rune(normalized); len(runes) > maxSuggestionPrefix {
+		key = string(runes[:maxSuggestionPrefix])
+		fetch = limit * 5
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "weight", Value: -1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(fetch)).
+		SetProjection(bson.M{"phrase": 1, "normalized": 1})
+
+	filter := bson.M{"scope": scope, "prefixes": key}
+	if len(excluded) > 0 {
+		filter["$or"] = []bson.M{
+			{"authors": bson.M{"$exists": false}},
+			{"sources": "query"},
+			{"authors": bson.M{"$elemMatch": bson.M{"$nin": excluded}}},
+			{fmt.Sprintf("authors.%d", maxSuggestionAuthors-1): bson.M{"$exists": true}},
+		}
+	}
+
+	cursor, err := database.MongoDB.Collection("suggestions").Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	suggestions := []string{}
+	for cursor.Next(ctx) {
+		var suggestion models.Suggestion
+		if err := cursor.Decode(&suggestion); err != nil {
+			log.Printf("Error decoding suggestion: %v", err)
+			continue
+		}
+		if key != normalized && !completesPrefix(suggestion.Normalized, normalized) {
+			continue
+		}
+		suggestions = append(suggestions, suggestion.Phrase)
+		if len(suggestions) >= limit {
+			break
+		}
+	}
+	return suggestions, cursor.Err()
+}
+
+// completesPrefix reports whether the phrase, or any of its word starts, begins with prefix
+func completesPrefix(phrase, prefix string) bool {
+	if strings.HasPrefix(phrase, prefix) {
+		return true
+	}
+	return strings.Contains(phrase, " "+prefix) || strings.Contains(phrase, " #"+prefix)
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// RebuildSuggestions recreates the suggestion store from the search index and the query log
+func (sc *SearchController) RebuildSuggestions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if _, err := database.MongoDB.Collection("suggestions").DeleteMany(ctx, bson.M{}); err != nil {
+		log.Printf("Error clearing suggestions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear suggestions"})
+		return
+	}
+
+	// Feed every public, visible indexed document
+	cursor, err := database.MongoDB.Collection("search_index").Find(ctx, listedFilter())
+	if err != nil {
+		log.Printf("Error reading search index: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read search index"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	documents := 0
+	for cursor.Next(ctx) {
+		var document models.SearchIndex
+		if err := cursor.Decode(&document); err != nil {
+			log.Printf("Error decoding document for suggestions: %v", err)
+			continue
+		}
+		if err := feedSuggestions(ctx, documentSuggestions(document), string(document.ContentType)); err != nil {
+			log.Printf("Error feeding suggestions: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild suggestions"})
+			return
+		}
+		documents++
+	}
+
+	// Replay searches from the query log that matched public content
+	queryCursor, err := database.MongoDB.Collection("search_queries").Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"endpoint": "search", "result_count": bson.M{"$gt": 0}, "public_match": true}},
+		{"$group": bson.M{
+			"_id":   bson.M{"query": "$query", "type": "$filters.type"},
+			"count": bson.M{"$sum": 1},
+		}},
+	})
+	if err != nil {
+		log.Printf("Error reading query log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read query log"})
+		return
+	}
+	defer queryCursor.Close(ctx)
+
+	queries := 0
+	for queryCursor.Next(ctx) {
+		var row struct {
+			ID struct {
+				Query string `bson:"query"`
+				Type  string `bson:"type"`
+			} `bson:"_id"`
+			Count float64 `bson:"count"`
+		}
+		if err := queryCursor.Decode(&row); err != nil {
+			continue
+		}
+		entry := suggestionEntry{Phrase: row.ID.Query, Weight: row.Count, Source: "query"}
+		if err := feedSuggestions(ctx, []suggestionEntry{entry}, row.ID.Type); err != nil {
+			log.Printf("Error feeding query suggestions: %v", err)
+			continue
+		}
+		queries++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Suggestions rebuilt successfully",
+		"documents": documents,
+		"queries":   queries,
+	})
+}
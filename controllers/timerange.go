@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/database"
+)
+
+// timePresets are the relative ranges accepted by the time parameter
+var timePresets = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+	"1y":  365 * 24 * time.Hour,
+}
+
+// histogramIntervals are the bucket sizes accepted by the histogram parameter
+var histogramIntervals = map[string]bool{"day": true, "week": true, "month": true}
+
+// maxHistogramBuckets caps the buckets returned for long time ranges
+const maxHistogramBuckets = 1000
+
+// timeRange limits results by creation date
+type timeRange struct {
+	Preset string
+	From   *time.Time
+	To     *time.Time
+}
+
+// parseTimeRange reads the time preset or the custom from/to parameters, which
+// accept RFC 3339 timestamps or YYYY-MM-DD dates. A to date covers that whole day.
+func parseTimeRange(c *gin.Context, now time.Time) (timeRange, error) {
+	var r timeRange
+	preset, from, to := c.Query("time"), c.Query("from"), c.Query("to")
+	if preset != "" {
+		if from != "" || to != "" {
+			return r, fmt.Errorf("time can't be combined with from or to")
+		}
+		duration, ok := timePresets[preset]
+		if !ok {
+			return r, fmt.Errorf("invalid time, expected 24h, 7d, 30d or 1y")
+		}
+		start := now.Add(-duration)
+		r.Preset, r.From = preset, &start
+		return r, nil
+	}
+
+	if from != "" {
+		start, err := parseDateParam(from, false)
+		if err != nil {
+			return r, fmt.Errorf("invalid from, expected an RFC 3339 timestamp or YYYY-MM-DD")
+		}
+		r.From = &start
+	}
+	if to != "" {
+		end, err := parseDateParam(to, true)
+		if err != nil {
+			return r, fmt.Errorf("invalid to, expected an RFC 3339 timestamp or YYYY-MM-DD")
+		}
+		r.To = &end
+	}
+	if r.From != nil && r.To != nil && !r.From.Before(*r.To) {
+		return r, fmt.Errorf("from must be before to")
+	}
+	return r, nil
+}
+
+// parseDateParam parses a timestamp or a date; dates used as an end bound
+// extend to the start of the next day
+func parseDateParam(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// Apply restricts the filter to the time range
+func (r timeRange) Apply(filter bson.M) {
+	if r.From == nil && r.To == nil {
+		return
+	}
+	createdAt := bson.M{}
+	if r.From != nil {
+		createdAt["$gte"] = *r.From
+	}
+	if r.To != nil {
+		createdAt["$lt"] = *r.To
+	}
+	filter["created_at"] = createdAt
+}
+
+// CacheKey identifies the range in result cache keys. Presets are keyed by
+// name, so their cached results lag by at most the cache TTL.
+func (r timeRange) CacheKey() string {
+	if r.Preset != "" {
+		return r.Preset
+	}
+	key := ""
+	if r.From != nil {
+		key += r.From.UTC().Format(time.RFC3339)
+	}
+	key += "~"
+	if r.To != nil {
+		key += r.To.UTC().Format(time.RFC3339)
+	}
+	return key
+}
+
+// histogramBucket is the number of matches created in one interval
+type histogramBucket struct {
+	Start time.Time `bson:"_id" json:"start"`
+	Count int64     `bson:"count" json:"count"`
+}
+
+// dateHistogram counts the documents matching the filter per day, week
+// (starting Monday) or month of creation, in UTC
+func dateHistogram(ctx context.Context, filter bson.M, interval string) ([]histogramBucket, error) {
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{
+			"_id": bson.M{"$dateTrunc": bson.M{
+				"date":        "$created_at",
+				"unit":        interval,
+				"startOfWeek": "monday",
+			}},
+			"count": bson.M{"$sum": 1},
+		}},
+		{"$sort": bson.M{"_id": -1}},
+		{"$limit": maxHistogramBuckets},
+		{"$sort": bson.M{"_id": 1}},
+	}
+
+	cursor, err := database.MongoDB.Collection("search_index").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	buckets := []histogramBucket{}
+	if err := cursor.All(ctx, &buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
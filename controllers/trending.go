@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/database"
+	"circleconnect-search/models"
+)
+
+// trendingWindow describes a sliding window over the bucketed activity counters
+type trendingWindow struct {
+	Span     time.Duration // Length of the window
+	Bucket   time.Duration // Counter granularity used for this window
+	Baseline time.Duration // Period before the window used to measure normal activity
+}
+
+// trendingWindows are the supported values of the window parameter
+var trendingWindows = map[string]trendingWindow{
+	"1h":  {Span: time.Hour, Bucket: time.Hour, Baseline: 24 * time.Hour},
+	"24h": {Span: 24 * time.Hour, Bucket: time.Hour, Baseline: 7 * 24 * time.Hour},
+	"7d":  {Span: 7 * 24 * time.Hour, Bucket: 24 * time.Hour, Baseline: 28 * 24 * time.Hour},
+}
+
+// Trending tuning
+const (
+	defaultTrendingWindow = "24h"
+	trendingBlockedKey    = "trends:blocked"
+	maxTrendingTermLength = 100
+	spikeVelocity         = 3.0 // Window rate must be this many times the baseline rate
+	minSpikeCount         = 5   // and the term must have been seen at least this often
+	hourlyRetention       = 9 * 24 * time.Hour
+	dailyRetention        = 40 * 24 * time.Hour
+)
+
+// trendingBucketKey names the sorted set counting activity in one time bucket
+func trendingBucketKey(bucket time.Duration, scope string, index int64) string {
+	if bucket == time.Hour {
+		return fmt.Sprintf("trends:h:%s:%d", scope, index)
+	}
+	return fmt.Sprintf("trends:d:%s:%d", scope, index)
+}
+
+// trendingScopes returns the counters a term is recorded under: everything, and
+// the content type when there is one
+func trendingScopes(contentType string) []string {
+	scopes := []string{"all"}
+	if contentType != "" {
+		scopes = append(scopes, contentType)
+	}
+	return scopes
+}
+
+// recordTrendingTerms counts search or hashtag activity in the hourly and daily
+// buckets. It runs in the background and is a no-op without Redis.
+func recordTrendingTerms(terms []string, contentType string) {
+	if RedisClient == nil {
+		return
+	}
+
+	var normalized []string
+	for _, term := range terms {
+		term = normalizeQuery(term)
+		if term != "" && len(term) <= maxTrendingTermLength {
+			normalized = append(normalized, term)
+		}
+	}
+	if len(normalized) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		now := time.Now().Unix()
+		pipe := RedisClient.Pipeline()
+		for _, scope := range trendingScopes(contentType) {
+			hourKey := trendingBucketKey(time.Hour, scope, now/3600)
+			dayKey := trendingBucketKey(24*time.Hour, scope, now/86400)
+			for _, term := range normalized {
+				pipe.ZIncrBy(ctx, hourKey, 1, term)
+				pipe.ZIncrBy(ctx, dayKey, 1, term)
+			}
+			pipe.Expire(ctx, hourKey, hourlyRetention)
+			pipe.Expire(ctx, dayKey, dailyRetention)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Error recording trending terms: %v", err)
+		}
+	}()
+}
+
+// queryTrendingTerms ranks terms by time-decayed activity in the window and
+// compares each term's rate with its rate over the preceding baseline period.
+// Counts aren't attributed to users, so excluded users are only filtered by
+// term: their IDs and @mentions are dropped as whole terms.
+func queryTrendingTerms(ctx context.Context, contentType string, window trendingWindow, limit int, excluded []string) ([]models.TrendingTerm, error) {
+	scope := "all"
+	if contentType != "" {
+		scope = contentType
+	}
+
+	now := time.Now().Unix()
+	bucketSecs := int64(window.Bucket / time.Second)
+	current := now / bucketSecs
+	elapsed := float64(now%bucketSecs) / float64(bucketSecs)
+	buckets := int64(window.Span / window.Bucket)
+	halfLife := float64(buckets) / 2
+
+	// The window covers the current bucket back to the oldest one, which only
+	// counts for the part still inside the sliding window
+	rawStore := &redis.ZStore{}
+	decayedStore := &redis.ZStore{}
+	for age := int64(0); age <= buckets; age++ {
+		weight := 1.0
+		if age == buckets {
+			weight = 1 - elapsed
+		}
+		key := trendingBucketKey(window.Bucket, scope, current-age)
+		rawStore.Keys = append(rawStore.Keys, key)
+		rawStore.Weights = append(rawStore.Weights, weight)
+		decayedStore.Keys = append(decayedStore.Keys, key)
+		decayedStore.Weights = append(decayedStore.Weights, weight*math.Exp(-math.Ln2*float64(age)/halfLife))
+	}
+
+	baselineStore := &redis.ZStore{}
+	baselineBuckets := int64(window.Baseline / window.Bucket)
+	for age := buckets + 1; age <= buckets+baselineBuckets; age++ {
+		baselineStore.Keys = append(baselineStore.Keys, trendingBucketKey(window.Bucket, scope, current-age))
+	}
+
+	// Union the buckets into short-lived scratch keys
+	scratch := "trends:tmp:" + newSearchID()
+	rawKey, decayedKey, baselineKey := scratch+":raw", scratch+":decayed", scratch+":baseline"
+	defer RedisClient.Del(context.Background(), rawKey, decayedKey, baselineKey)
+
+	pipe := RedisClient.Pipeline()
+	pipe.ZUnionStore(ctx, rawKey, rawStore)
+	pipe.ZUnionStore(ctx, decayedKey, decayedStore)
+	pipe.ZUnionStore(ctx, baselineKey, baselineStore)
+	for _, key := range []string{rawKey, decayedKey, baselineKey} {
+		pipe.Expire(ctx, key, 30*time.Second)
+	}
+	candidatesCmd := pipe.ZRevRangeWithScores(ctx, decayedKey, 0, int64(limit*4-1))
+	blockedCmd := pipe.SMembers(ctx, trendingBlockedKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	blocked := make(map[string]bool)
+	for _, term := range blockedCmd.Val() {
+		blocked[term] = true
+	}
+	excludedTerms := make(map[string]bool, 2*len(excluded))
+	for _, userID := range excluded {
+		excludedTerms[strings.ToLower(userID)] = true
+		excludedTerms["@"+strings.ToLower(userID)] = true
+	}
+
+	// Drop stopwords and blocked terms before taking the top results
+	var terms []models.TrendingTerm
+	var members []string
+	for _, candidate := range candidatesCmd.Val() {
+		term, _ := candidate.Member.(string)
+		if excludedTerms[term] || !isTrendingCandidate(term, blocked) {
+			continue
+		}
+		terms = append(terms, models.TrendingTerm{Term: term, Score: candidate.Score})
+		members = append(members, term)
+		if len(terms) >= limit {
+			break
+		}
+	}
+	if len(terms) == 0 {
+		return terms, nil
+	}
+
+	pipe = RedisClient.Pipeline()
+	rawCmd := pipe.ZMScore(ctx, rawKey, members...)
+	baselineCmd := pipe.ZMScore(ctx, baselineKey, members...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	// Velocity compares the hourly rate in the window with the baseline rate;
+	// a baseline of at least one occurrence keeps brand new terms finite
+	windowHours := window.Span.Hours()
+	baselineHours := window.Baseline.Hours()
+	for i := range terms {
+		count := rawCmd.Val()[i]
+		baselineRate := math.Max(baselineCmd.Val()[i], 1) / baselineHours
+		terms[i].Count = int(math.Round(count))
+		terms[i].Velocity = (count / windowHours) / baselineRate
+		terms[i].Spike = terms[i].Velocity >= spikeVelocity && terms[i].Count >= minSpikeCount
+	}
+
+	return terms, nil
+}
+
+// isTrendingCandidate filters out stopwords, very short terms and blocked terms.
+// A term is blocked when it or any of its words is on the block list.
+func isTrendingCandidate(term string, blocked map[string]bool) bool {
+	if len(term) < 2 || analysis.AllStopwords(term) || blocked[term] {
+		return false
+	}
+	for _, word := range strings.Fields(term) {
+		if blocked[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// indexTrendingTerms aggregates autocomplete phrases across indexed documents
+// weighted by popularity. It is the fallback when Redis is unavailable.
+func indexTrendingTerms(ctx context.Context, contentType string, limit int, excluded []string) ([]models.TrendingTerm, error) {
+	// Prepare the aggregation pipeline over public content of users the caller didn't exclude
+	match := listedFilter()
+	if len(excluded) > 0 {
+		match["author"] = bson.M{"$nin": excluded}
+	}
+	pipeline := []bson.M{
+		{"$match": match},
+		{
+			"$project": bson.M{
+				"phrases":          "$autocomplete_phrases",
+				"content_type":     1,
+				"popularity_score": 1,
+			},
+		},
+	}
+
+	// Add content type filter if specified
+	if contentType != "" {
+		pipeline = append(pipeline, bson.M{
+			"$match": bson.M{
+				"content_type": contentType,
+			},
+		})
+	}
+
+	// Unwind the phrases array to get individual phrases
+	pipeline = append(pipeline, bson.M{
+		"$unwind": "$phrases",
+	})
+
+	// Group by phrase and sum popularity scores
+	pipeline = append(pipeline, bson.M{
+		"$group": bson.M{
+			"_id":   "$phrases",
+			"score": bson.M{"$sum": "$popularity_score"},
+			"count": bson.M{"$sum": 1},
+		},
+	})
+
+	// Sort by score (descending)
+	pipeline = append(pipeline, bson.M{
+		"$sort": bson.M{
+			"score": -1,
+			"count": -1,
+		},
+	})
+
+	// Limit the results
+	pipeline = append(pipeline, bson.M{
+		"$limit": limit,
+	})
+
+	// Execute the aggregation
+	cursor, err := database.MongoDB.Collection("search_index").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var trendingTerms []models.TrendingTerm
+	if err := cursor.All(ctx, &trendingTerms); err != nil {
+		return nil, err
+	}
+	return trendingTerms, nil
+}
+
+// BlockTrendingTerms adds terms to the trending block list
+func (sc *SearchController) BlockTrendingTerms(c *gin.Context) {
+	var request struct {
+		Terms []string `json:"terms" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if RedisClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Redis is not available"})
+		return
+	}
+
+	terms := make([]any, 0, len(request.Terms))
+	for _, term := range request.Terms {
+		if term = normalizeQuery(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	added, err := RedisClient.SAdd(ctx, trendingBlockedKey, terms...).Result()
+	if err != nil {
+		log.Printf("Error blocking trending terms: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block terms"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Terms blocked successfully", "added": added})
+}
+
+// UnblockTrendingTerm removes a term from the trending block list
+func (sc *SearchController) UnblockTrendingTerm(c *gin.Context) {
+	if RedisClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Redis is not available"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	removed, err := RedisClient.SRem(ctx, trendingBlockedKey, normalizeQuery(c.Param("term"))).Result()
+	if err != nil {
+		log.Printf("Error unblocking trending term: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock term"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Term unblocked successfully", "removed": removed})
+}
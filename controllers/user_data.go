@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/database"
+	"circleconnect-search/models"
+)
+
+// globEscaper escapes Redis glob metacharacters in a literal
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// userCachePatterns returns the SCAN patterns of cached responses whose key names
+// the user in one of its segments: as the viewer scope, as the author scope, or
+// as an @mention of their ID or one of their usernames in the query
+func userCachePatterns(userID string, usernames ...string) []string {
+	id := globEscaper.Replace(userID)
+	patterns := []string{
+		"search:user:" + id + ":*",
+		"search:all:user:" + id + ":*",
+		"similar:user:" + id + ":*",
+		"search:*[:&]author=" + globEscaper.Replace(url.QueryEscape(userID)) + ":*",
+	}
+	var mentions []string
+	for _, name := range append([]string{userID, strings.ToLower(userID)}, usernames...) {
+		mentions = append(mentions, globEscaper.Replace(name))
+	}
+	for _, mention := range uniqueIDs(mentions) {
+		patterns = append(patterns, "search:*@"+mention+"[ :]*", "suggestions:*@"+mention+"[ :]*")
+	}
+	return patterns
+}
+
+// authoredFilter matches a user's indexed content and their profile document
+func authoredFilter(userID string) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"author": userID},
+		{"content_type": models.User, "content_id": userID},
+	}}
+}
+
+// deleteCachedMentions deletes cached responses whose key mentions the user by
+// ID or username, along with the user's cached memberships, block list and profile
+func deleteCachedMentions(ctx context.Context, userID string, usernames []string) int {
+	if RedisClient == nil {
+		return 0
+	}
+
+	keys := []string{"acl:memberships:" + userID, "acl:excluded:" + userID, "enrich:user:" + userID}
+	for _, pattern := range userCachePatterns(userID, usernames...) {
+		iter := RedisClient.Scan(ctx, 0, pattern, 1000).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			log.Printf("Error scanning cache keys: %v", err)
+		}
+	}
+
+	deleted, err := RedisClient.Del(ctx, keys...).Result()
+	if err != nil {
+		log.Printf("Error deleting cached entries: %v", err)
+		return 0
+	}
+	return int(deleted)
+}
+
+// userUsernames returns the usernames a user is @mentioned by: the one of their
+// indexed profile and the one of their Postgres profile, when it still exists
+func userUsernames(ctx context.Context, userID string, authored []models.SearchIndex) []string {
+	var usernames []string
+	for _, document := range authored {
+		if document.ContentType == models.User && document.ContentID == userID && document.Username != "" {
+			usernames = append(usernames, document.Username)
+		}
+	}
+	if database.PgDB != nil {
+		profiles, err := userProfiles(ctx, []string{userID})
+		if err != nil {
+			log.Printf("Error loading user profile: %v", err)
+		}
+		if username := analysis.NormalizeUsername(profiles[userID].Username); username != "" {
+			usernames = append(usernames, username)
+		}
+	}
+	return uniqueIDs(usernames)
+}
+
+// EraseUserData deletes everything the search service holds about a user: their
+// indexed content and profile, query log, clicks, history, saved searches and
+// alerts, suggestions only they contributed, and cached entries mentioning them.
+// It is idempotent, so a failed erasure can be retried.
+func (sc *SearchController) EraseUserData(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// Collect the content IDs and usernames first so cached results including
+	// them or mentioning the user can be dropped
+	var authored []models.SearchIndex
+	cursor, err := database.MongoDB.Collection("search_index").Find(ctx, authoredFilter(userID),
+		options.Find().SetProjection(bson.M{"content_id": 1, "content_type": 1, "username": 1}))
+	if err == nil {
+		err = cursor.All(ctx, &authored)
+	}
+	if err != nil {
+		log.Printf("Erase user data error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read user content"})
+		return
+	}
+	contentIDs := make([]string, len(authored))
+	for i, document := range authored {
+		contentIDs[i] = document.ContentID
+	}
+	usernames := userUsernames(ctx, userID, authored)
+
+	deletions := []struct {
+		collection string
+		filter     bson.M
+	}{
+		{"search_index", authoredFilter(userID)},
+		{"search_queries", bson.M{"user_id": userID}},
+		{"search_clicks", bson.M{"user_id": userID}},
+		{"recent_searches", bson.M{"user_id": userID}},
+		{"saved_searches", bson.M{"user_id": userID}},
+		{"suggestions", bson.M{"authors": []string{userID}, "sources": bson.M{"$ne": "query"}}},
+	}
+
+	deleted := gin.H{}
+	for _, deletion := range deletions {
+		result, err := database.MongoDB.Collection(deletion.collection).DeleteMany(ctx, deletion.filter)
+		if err != nil {
+			log.Printf("Erase user data error on %s: %v", deletion.collection, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase user data from " + deletion.collection})
+			return
+		}
+		deleted[deletion.collection] = result.DeletedCount
+	}
+
+	// Shared suggestions keep their phrase but forget the user as an author
+	_, err = database.MongoDB.Collection("suggestions").UpdateMany(ctx,
+		bson.M{"authors": userID},
+		bson.M{"$pull": bson.M{"authors": userID}})
+	if err != nil {
+		log.Printf("Erase user data error on suggestions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase user data from suggestions"})
+		return
+	}
+
+	invalidated := invalidateContentCache(ctx, contentIDs) + deleteCachedMentions(ctx, userID, usernames)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "User data erased successfully",
+		"user_id":     userID,
+		"deleted":     deleted,
+		"invalidated": invalidated,
+	})
+}
+
+// ExportUserData returns everything the search service holds about a user as JSON
+func (sc *SearchController) ExportUserData(c *gin.Context) {
+	userID := c.Param("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	documents := []models.SearchIndex{}
+	queries := []models.SearchEvent{}
+	clicks := []models.ClickEvent{}
+	recent := []models.RecentSearch{}
+	saved := []models.SavedSearch{}
+
+	exports := []struct {
+		collection string
+		filter     bson.M
+		sort       bson.M
+		results    any
+	}{
+		{"search_index", authoredFilter(userID), bson.M{"created_at": -1}, &documents},
+		{"search_queries", bson.M{"user_id": userID}, bson.M{"created_at": -1}, &queries},
+		{"search_clicks", bson.M{"user_id": userID}, bson.M{"created_at": -1}, &clicks},
+		{"recent_searches", bson.M{"user_id": userID}, bson.M{"last_searched_at": -1}, &recent},
+		{"saved_searches", bson.M{"user_id": userID}, bson.M{"name": 1}, &saved},
+	}
+
+	for _, export := range exports {
+		cursor, err := database.MongoDB.Collection(export.collection).Find(ctx, export.filter, options.Find().SetSort(export.sort))
+		if err == nil {
+			err = cursor.All(ctx, export.results)
+		}
+		if err != nil {
+			log.Printf("Export user data error on %s: %v", export.collection, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export user data from " + export.collection})
+			return
+		}
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "search-data-" + userID + ".json"}))
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":         userID,
+		"exported_at":     time.Now(),
+		"documents":       documents,
+		"search_queries":  queries,
+		"clicks":          clicks,
+		"recent_searches": recent,
+		"saved_searches":  saved,
+	})
+}
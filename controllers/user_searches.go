@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"circleconnect-search/database"
+	"circleconnect-search/middleware"
+	"circleconnect-search/models"
+)
+
+// Personal search history limits
+const (
+	maxRecentSearches     = 50 // Recent queries kept per user
+	maxRecentSuggestions  = 3  // Recent queries shown ahead of global suggestions
+	maxSavedSearches      = 100
+	defaultRecentListSize = 20
+)
+
+// savedSearchFilters are the Search parameters a saved search may carry
+var savedSearchFilters = map[string]bool{"type": true, "lang": true}
+
+// recordRecentSearch remembers a query for the authenticated user in the background
+func recordRecentSearch(c *gin.Context, query string) {
+	user, ok := middleware.CurrentUser(c)
+	normalized := normalizeQuery(query)
+	if !ok || database.MongoDB == nil || normalized == "" {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		collection := database.MongoDB.Collection("recent_searches")
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"_id": user.ID + ":" + normalized},
+			bson.M{
+				"$set": bson.M{"user_id": user.ID, "query": normalized, "last_searched_at": time.Now()},
+				"$inc": bson.M{"count": 1},
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			log.Printf("Error recording recent search: %v", err)
+			return
+		}
+
+		// Keep only the most recent entries
+		var oldest models.RecentSearch
+		err = collection.FindOne(ctx, bson.M{"user_id": user.ID}, options.FindOne().
+			SetSort(bson.M{"last_searched_at": -1}).
+			SetSkip(maxRecentSearches)).Decode(&oldest)
+		if err == nil {
+			collection.DeleteMany(ctx, bson.M{"user_id": user.ID, "last_searched_at": bson.M{"$lte": oldest.LastSearchedAt}})
+		}
+	}()
+}
+
+// recentSuggestions returns the user's most recent queries starting with the prefix
+func recentSuggestions(ctx context.Context, userID string, prefix string) ([]string, error) {
+	normalized := normalizeQuery(prefix)
+	findOptions := options.Find().
+		SetSort(bson.M{"last_searched_at": -1}).
+		SetLimit(maxRecentSuggestions)
+
+	cursor, err := database.MongoDB.Collection("recent_searches").Find(ctx, bson.M{
+		"user_id": userID,
+		"query":   bson.M{"$regex": "^" + regexp.QuoteMeta(normalized)},
+	}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var recent []models.RecentSearch
+	if err := cursor.All(ctx, &recent); err != nil {
+		return nil, err
+	}
+
+	queries := make([]string, len(recent))
+	for i, r := range recent {
+		queries[i] = r.Query
+	}
+	return queries, nil
+}
+
+// personalizeSuggestions puts the user's own recent matches ahead of the global
+// suggestions. It runs after caching so personal history is never shared.
+func personalizeSuggestions(ctx context.Context, userID string, prefix string, responseData gin.H) {
+	recent, err := recentSuggestions(ctx, userID, prefix)
+	if err != nil {
+		log.Printf("Error fetching recent suggestions: %v", err)
+		return
+	}
+	if len(recent) == 0 {
+		return
+	}
+
+	suggestions := append([]string{}, recent...)
+	seen := make(map[string]bool, len(recent))
+	for _, query := range recent {
+		seen[query] = true
+	}
+	for _, suggestion := range toStrings(responseData["suggestions"]) {
+		if !seen[normalizeQuery(suggestion)] {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+
+	responseData["suggestions"] = suggestions
+	responseData["recent"] = recent
+}
+
+// toStrings converts a response list, fresh or decoded from the cache, to strings
+func toStrings(value any) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// RecentSearches lists the authenticated user's recent queries, newest first
+func (sc *SearchController) RecentSearches(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().
+		SetSort(bson.M{"last_searched_at": -1}).
+		SetLimit(defaultRecentListSize)
+
+	cursor, err := database.MongoDB.Collection("recent_searches").Find(ctx, bson.M{"user_id": user.ID}, findOptions)
+	if err != nil {
+		log.Printf("Recent searches error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recent searches"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	recent := []models.RecentSearch{}
+	if err := cursor.All(ctx, &recent); err != nil {
+		log.Printf("Error processing recent searches: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process recent searches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recent": recent, "count": len(recent)})
+}
+
+// ClearRecentSearches deletes the authenticated user's search history
+func (sc *SearchController) ClearRecentSearches(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := database.MongoDB.Collection("recent_searches").DeleteMany(ctx, bson.M{"user_id": user.ID})
+	if err != nil {
+		log.Printf("Clear recent searches error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear recent searches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Recent searches cleared successfully",
+		"deleted_count": result.DeletedCount,
+	})
+}
+
+// SavedSearches lists the authenticated user's saved searches by name
+func (sc *SearchController) SavedSearches(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := database.MongoDB.Collection("saved_searches").Find(ctx,
+		bson.M{"user_id": user.ID},
+		options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		log.Printf("Saved searches error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch saved searches"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	saved := []models.SavedSearch{}
+	if err := cursor.All(ctx, &saved); err != nil {
+		log.Printf("Error processing saved searches: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process saved searches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"saved": saved, "count": len(saved)})
+}
+
+// SaveSearch stores a named search with filters for the authenticated user
+func (sc *SearchController) SaveSearch(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	var saved models.SavedSearch
+	if err := c.ShouldBindJSON(&saved); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for key := range saved.Filters {
+		if !savedSearchFilters[key] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported filter: " + key})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := database.MongoDB.Collection("saved_searches")
+	count, err := collection.CountDocuments(ctx, bson.M{"user_id": user.ID})
+	if err != nil {
+		log.Printf("Saved search count error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save search"})
+		return
+	}
+	if count >= maxSavedSearches {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Saved search limit reached"})
+		return
+	}
+
+	now := time.Now()
+	saved.ID = primitive.NewObjectID()
+	saved.UserID = user.ID
+	saved.CreatedAt = now
+	saved.UpdatedAt = now
+	// Alerts need analyzed terms, so they are only set through the alert endpoint
+	saved.Alert = nil
+	saved.AlertTerms = nil
+
+	if _, err := collection.InsertOne(ctx, saved); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A saved search with this name already exists"})
+			return
+		}
+		log.Printf("Save search error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save search"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, saved)
+}
+
+// DeleteSavedSearch removes one of the authenticated user's saved searches
+func (sc *SearchController) DeleteSavedSearch(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := database.MongoDB.Collection("saved_searches").DeleteOne(ctx, bson.M{"_id": id, "user_id": user.ID})
+	if err != nil {
+		log.Printf("Delete saved search error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete saved search"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted successfully"})
+}
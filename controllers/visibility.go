@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/database"
+	"circleconnect-search/middleware"
+	"circleconnect-search/models"
+)
+
+// Visibility tuning
+const (
+	communityMembersTable = "community_members"
+	userBlocksTable       = "user_blocks"
+	userMutesTable        = "user_mutes"
+	membershipCacheTTL    = 5 * time.Minute
+	exclusionCacheTTL     = 5 * time.Minute
+)
+
+// exclusionHTTPClient calls the block list service
+var exclusionHTTPClient = &http.Client{Timeout: 2 * time.Second}
+
+// searchViewer is the identity results are filtered for. The zero value is an
+// anonymous caller, who only sees public content.
+type searchViewer struct {
+	UserID      string
+	Communities []string // IDs of communities the user is a member of
+	Excluded    []string // IDs of users the user blocked or muted
+}
+
+// resolveViewer builds the viewer for the request from the JWT identity and the
+// user's community memberships. Membership lookup failures fail closed: the user
+// still sees public content and their own, but no community content.
+func resolveViewer(ctx context.Context, c *gin.Context) searchViewer {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		return searchViewer{}
+	}
+	return viewerFor(ctx, user.ID)
+}
+
+// resolveExclusions builds the viewer for the request with only the user's block
+// list, for responses like suggestions that never depend on memberships
+func resolveExclusions(ctx context.Context, c *gin.Context) searchViewer {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		return searchViewer{}
+	}
+	return exclusionsFor(ctx, user.ID)
+}
+
+// viewerFor builds the viewer for a user ID
+func viewerFor(ctx context.Context, userID string) searchViewer {
+	viewer := exclusionsFor(ctx, userID)
+	communities, err := communityMemberships(ctx, userID)
+	if err != nil {
+		log.Printf("Error resolving community memberships: %v", err)
+	}
+	viewer.Communities = communities
+	return viewer
+}
+
+// exclusionsFor builds the viewer for a user ID without their memberships
+func exclusionsFor(ctx context.Context, userID string) searchViewer {
+	viewer := searchViewer{UserID: userID}
+	excluded, err := excludedUsers(ctx, userID)
+	if err != nil {
+		log.Printf("Error resolving blocked users: %v", err)
+	}
+	viewer.Excluded = excluded
+	return viewer
+}
+
+// communityMemberships returns the IDs of the communities a user belongs to,
+// read from Postgres and cached in Redis for a few minutes
+func communityMemberships(ctx context.Context, userID string) ([]string, error) {
+	cacheKey := "acl:memberships:" + userID
+	if RedisClient != nil {
+		if cached, err := RedisClient.Get(ctx, cacheKey).Result(); err == nil {
+			var communities []string
+			if err := json.Unmarshal([]byte(cached), &communities); err == nil {
+				return communities, nil
+			}
+		}
+	}
+
+	if database.PgDB == nil {
+		return nil, fmt.Errorf("postgres not connected")
+	}
+
+	var communities []string
+	err := database.PgDB.WithContext(ctx).
+		Table(communityMembersTable).
+		Where("user_id = ?", userID).
+		Distinct().
+		Pluck("community_id", &communities).Error
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(communities)
+
+	if RedisClient != nil {
+		if data, err := json.Marshal(communities); err == nil {
+			RedisClient.Set(ctx, cacheKey, data, membershipCacheTTL)
+		}
+	}
+	return communities, nil
+}
+
+// excludedUsers returns the IDs of users the user blocked or muted, from the
+// BLOCK_LIST_URL service when configured and Postgres otherwise, cached in Redis
+func excludedUsers(ctx context.Context, userID string) ([]string, error) {
+	cacheKey := "acl:excluded:" + userID
+	if RedisClient != nil {
+		if cached, err := RedisClient.Get(ctx, cacheKey).Result(); err == nil {
+			var excluded []string
+			if err := json.Unmarshal([]byte(cached), &excluded); err == nil {
+				return excluded, nil
+			}
+		}
+	}
+
+	var blocked, muted []string
+	if serviceURL := os.Getenv("BLOCK_LIST_URL"); serviceURL != "" {
+		var err error
+		blocked, muted, err = fetchBlockList(ctx, serviceURL, userID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if database.PgDB == nil {
+			return nil, fmt.Errorf("postgres not connected")
+		}
+		err := database.PgDB.WithContext(ctx).
+			Table(userBlocksTable).
+			Where("user_id = ?", userID).
+			Pluck("blocked_user_id", &blocked).Error
+		if err != nil {
+			return nil, err
+		}
+		err = database.PgDB.WithContext(ctx).
+			Table(userMutesTable).
+			Where("user_id = ?", userID).
+			Pluck("muted_user_id", &muted).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	excluded := uniqueIDs(append(blocked, muted...))
+	sort.Strings(excluded)
+
+	if RedisClient != nil {
+		if data, err := json.Marshal(excluded); err == nil {
+			RedisClient.Set(ctx, cacheKey, data, exclusionCacheTTL)
+		}
+	}
+	return excluded, nil
+}
+
+// uniqueIDs returns the IDs without duplicates, in order. IDs are compared
+// exactly, unlike phrases, since IDs differing only in case are different users.
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	var unique []string
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// fetchBlockList asks the block list service for a user's blocked and muted users
+func fetchBlockList(ctx context.Context, serviceURL string, userID string) ([]string, []string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serviceURL+"?user_id="+url.QueryEscape(userID), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if apiKey := os.Getenv("SERVICE_API_KEY"); apiKey != "" {
+		req.Header.Set("X-Service-API-Key", apiKey)
+	}
+
+	resp, err := exclusionHTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("block list service returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Blocked []string `json:"blocked"`
+		Muted   []string `json:"muted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, nil, err
+	}
+	return body.Blocked, body.Muted, nil
+}
+
+// publicFilter matches documents anyone may see. Documents indexed before
+// visibility existed have no visibility field and are public.
+func publicFilter() bson.M {
+	return bson.M{"visibility": bson.M{"$in": []any{nil, models.VisibilityPublic}}}
+}
+
+// Filter matches the documents the viewer may see, leaving out content and
+// profiles of blocked and muted users
+func (v searchViewer) Filter() bson.M {
+	if v.UserID == "" {
+		return publicFilter()
+	}
+
+	entries := []any{nil, models.VisibilityPublic}
+	for _, community := range v.Communities {
+		entries = append(entries, models.VisibilityCommunityPrefix+community)
+	}
+	filter := bson.M{"$or": []bson.M{
+		{"visibility": bson.M{"$in": entries}},
+		{"visibility": models.VisibilityAuthorOnly, "author": v.UserID},
+	}}
+
+	if len(v.Excluded) > 0 {
+		filter["author"] = bson.M{"$nin": v.Excluded}
+		filter["$nor"] = []bson.M{{"content_type": models.User, "content_id": bson.M{"$in": v.Excluded}}}
+	}
+	return filter
+}
+
+// CanView reports whether the viewer may see a document
+func (v searchViewer) CanView(doc models.SearchIndex) bool {
+	if v.IsExcluded(doc.Author) || (doc.ContentType == models.User && v.IsExcluded(doc.ContentID)) {
+		return false
+	}
+	if doc.IsPublic() {
+		return true
+	}
+	if v.UserID == "" {
+		return false
+	}
+	for _, entry := range doc.Visibility {
+		if entry == models.VisibilityAuthorOnly && doc.Author == v.UserID {
+			return true
+		}
+		if community, ok := strings.CutPrefix(entry, models.VisibilityCommunityPrefix); ok && containsString(v.Communities, community) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExcluded reports whether the viewer blocked or muted the user
+func (v searchViewer) IsExcluded(userID string) bool {
+	return userID != "" && containsString(v.Excluded, userID)
+}
+
+// CacheScope identifies the set of documents the viewer may see, so cached
+// results are only shared between callers with the same access. Membership and
+// block list changes produce a new scope once their caches expire.
+func (v searchViewer) CacheScope() string {
+	if v.UserID == "" {
+		return "public"
+	}
+	hash := sha1.Sum([]byte(strings.Join(v.Communities, ",") + "|" + strings.Join(v.Excluded, ",")))
+	return "user:" + v.UserID + ":" + hex.EncodeToString(hash[:6])
+}
+
+// ExclusionScope identifies the viewer's block list for responses that only
+// depend on it. Viewers who blocked no one share the "all" scope.
+func (v searchViewer) ExclusionScope() string {
+	if len(v.Excluded) == 0 {
+		return "all"
+	}
+	hash := sha1.Sum([]byte(strings.Join(v.Excluded, ",")))
+	return "excl:" + hex.EncodeToString(hash[:6])
+}
+
+// andFilter adds a clause to the filter's $and list
+func andFilter(filter bson.M, clause bson.M) {
+	clauses, _ := filter["$and"].([]bson.M)
+	filter["$and"] = append(clauses, clause)
+}
+
+// validateVisibility checks that every ACL entry has a supported form
+func validateVisibility(entries []string) error {
+	for _, entry := range entries {
+		switch {
+		case entry == models.VisibilityPublic, entry == models.VisibilityAuthorOnly:
+		case strings.HasPrefix(entry, models.VisibilityCommunityPrefix) && len(entry) > len(models.VisibilityCommunityPrefix):
+		default:
+			return fmt.Errorf("unsupported visibility entry: %s", entry)
+		}
+	}
+	return nil
+}
@@ -2,33 +2,74 @@ package database
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"circleconnect-search/ranking"
 )
 
+// TextIndexModel returns the weighted text index used for full-text search
+func TextIndexModel() mongo.IndexModel {
+	keys := bson.D{}
+	for _, field := range ranking.TextIndexWeights {
+		keys = append(keys, bson.E{Key: field.Key, Value: "text"})
+	}
+
+	return mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetWeights(ranking.TextIndexWeights).SetName("text_search_index"),
+	}
+}
+
+// isIndexConflict reports whether an index already exists with other options or keys
+func isIndexConflict(err error) bool {
+	var commandErr mongo.CommandError
+	return errors.As(err, &commandErr) && (commandErr.Code == 85 || commandErr.Code == 86)
+}
+
+// EnsureTextIndex creates text_search_index if it is missing. An existing text
+// index with another definition is left in place; only startup replaces it.
+func EnsureTextIndex(ctx context.Context) error {
+	_, err := MongoDB.Collection("search_index").Indexes().CreateOne(ctx, TextIndexModel())
+	if isIndexConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// migrateTextIndex creates text_search_index at startup. A collection can only
+// have one text index, so an older definition with different fields or weights
+// is dropped and replaced. Running it only at startup keeps instances of
+// different versions from dropping each other's index while serving.
+func migrateTextIndex(ctx context.Context) error {
+	indexes := MongoDB.Collection("search_index").Indexes()
+	_, err := indexes.CreateOne(ctx, TextIndexModel())
+
+	if isIndexConflict(err) {
+		log.Println("Text index definition changed, recreating text_search_index")
+		if _, dropErr := indexes.DropOne(ctx, "text_search_index"); dropErr != nil {
+			return dropErr
+		}
+		_, err = indexes.CreateOne(ctx, TextIndexModel())
+	}
+	return err
+}
+
 // InitIndexes creates all required indexes for the search collection
 func InitIndexes() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	// Create text indexes for search
-	textIndexModel := mongo.IndexModel{
-		Keys: bson.D{
-			{Key: "title", Value: "text"},
-			{Key: "content", Value: "text"},
-			{Key: "tags", Value: "text"},
-			{Key: "autocomplete_phrases", Value: "text"},
-		},
-		Options: options.Index().SetWeights(bson.D{
-			{Key: "title", Value: 10},
-			{Key: "tags", Value: 5},
-			{Key: "autocomplete_phrases", Value: 3},
-			{Key: "content", Value: 1},
-		}).SetName("text_search_index"),
+	if err := migrateTextIndex(ctx); err != nil {
+		log.Printf("Warning: Failed to create text index: %v", err)
+	} else {
+		log.Println("Created index: search_index.text_search_index")
 	}
 
 	// Create prefix indexes for autocomplete
@@ -47,6 +88,23 @@ func InitIndexes() {
 		Options: options.Index().SetName("autocomplete_phrases_index"),
 	}
 
+	// Entity indexes for hashtag lookup and #tag / @user queries
+	hashtagsIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "hashtags", Value: 1}},
+		Options: options.Index().SetName("hashtags_index"),
+	}
+
+	mentionsIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "mentions", Value: 1}},
+		Options: options.Index().SetName("mentions_index"),
+	}
+
+	// Language index for the lang filter
+	langIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "lang", Value: 1}, {Key: "content_type", Value: 1}},
+		Options: options.Index().SetName("lang_content_type_index"),
+	}
+
 	// Content type index for filtering
 	contentTypeIndex := mongo.IndexModel{
 		Keys:    bson.D{{Key: "content_type", Value: 1}},
@@ -62,24 +120,170 @@ func InitIndexes() {
 		Options: options.Index().SetName("content_type_date_index"),
 	}
 
+	// Visibility index for permission filtering
+	visibilityIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "visibility", Value: 1}, {Key: "content_type", Value: 1}},
+		Options: options.Index().SetName("visibility_content_type_index"),
+	}
+
+	// Moderation index for review queues and state filtering
+	moderationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "moderation_state", Value: 1}, {Key: "content_type", Value: 1}},
+		Options: options.Index().SetName("moderation_state_content_type_index"),
+	}
+
+	// Embedding model index for semantic scans and re-embedding
+	embeddingModelIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "embedding_model", Value: 1}, {Key: "content_type", Value: 1}},
+		Options: options.Index().SetName("embedding_model_content_type_index"),
+	}
+
+	// People search indexes for username and display name prefix matching
+	usernameIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "content_type", Value: 1}, {Key: "username", Value: 1}},
+		Options: options.Index().SetName("content_type_username_index"),
+	}
+
+	nameTokensIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "content_type", Value: 1}, {Key: "name_tokens", Value: 1}},
+		Options: options.Index().SetName("content_type_name_tokens_index"),
+	}
+
+	// Community index for category and size filters
+	communityIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "content_type", Value: 1},
+			{Key: "community.category", Value: 1},
+			{Key: "community.member_count", Value: -1},
+		},
+		Options: options.Index().SetName("community_category_members_index"),
+	}
+
+	// Content lookups by ID, e.g. the parent posts of comment results
+	contentIDIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "content_type", Value: 1}, {Key: "content_id", Value: 1}},
+		Options: options.Index().SetName("content_type_content_id_index"),
+	}
+
+	// Scope indexes for searches within a community or by an author
+	communityScopeIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "community_id", Value: 1},
+			{Key: "content_type", Value: 1},
+			{Key: "created_at", Value: -1},
+		},
+		Options: options.Index().SetName("community_content_type_date_index"),
+	}
+
+	authorScopeIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "author", Value: 1},
+			{Key: "content_type", Value: 1},
+			{Key: "created_at", Value: -1},
+		},
+		Options: options.Index().SetName("author_content_type_date_index"),
+	}
+
+	// Geo index for near/radius searches
+	locationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "location", Value: "2dsphere"}},
+		Options: options.Index().SetName("location_2dsphere_index"),
+	}
+
 	// Create all indexes
 	indexes := []mongo.IndexModel{
-		textIndexModel,
 		titlePrefixIndex,
 		tagsPrefixIndex,
 		autocompletePrefixIndex,
+		hashtagsIndex,
+		mentionsIndex,
+		langIndex,
 		contentTypeIndex,
 		dateContentTypeIndex,
+		visibilityIndex,
+		moderationIndex,
+		embeddingModelIndex,
+		usernameIndex,
+		nameTokensIndex,
+		communityIndex,
+		contentIDIndex,
+		communityScopeIndex,
+		authorScopeIndex,
+		locationIndex,
 	}
 
+	createIndexes(ctx, "search_index", indexes)
+
+	// Query log indexes for analytics by time, by user and for click joins
+	createIndexes(ctx, "search_queries", []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "search_id", Value: 1}},
+			Options: options.Index().SetName("search_id_index").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("created_at_index"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("user_date_index"),
+		},
+	})
+
+	// Autocomplete lookups are an equality match on prefixes within a scope, sorted by weight
+	createIndexes(ctx, "suggestions", []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "scope", Value: 1}, {Key: "prefixes", Value: 1}, {Key: "weight", Value: -1}},
+			Options: options.Index().SetName("scope_prefix_weight_index"),
+		},
+	})
+
+	// Personal history: newest first per user, and prefix lookups for suggestions
+	createIndexes(ctx, "recent_searches", []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "last_searched_at", Value: -1}},
+			Options: options.Index().SetName("user_date_index"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "query", Value: 1}},
+			Options: options.Index().SetName("user_query_index"),
+		},
+	})
+
+	createIndexes(ctx, "saved_searches", []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetName("user_name_index").SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "alert_terms", Value: 1}},
+			Options: options.Index().SetName("alert_terms_index").
+				SetPartialFilterExpression(bson.M{"alert.enabled": true}),
+		},
+	})
+
+	createIndexes(ctx, "search_clicks", []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "search_id", Value: 1}},
+			Options: options.Index().SetName("search_id_index"),
+		},
+		{
+			Keys:    bson.D{{Key: "content_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("content_date_index"),
+		},
+	})
+
+	log.Println("Finished initializing MongoDB indexes")
+}
+
+// createIndexes creates each index on a collection, logging failures without aborting
+func createIndexes(ctx context.Context, collection string, indexes []mongo.IndexModel) {
 	for _, index := range indexes {
-		indexName, err := MongoDB.Collection("search_index").Indexes().CreateOne(ctx, index)
+		indexName, err := MongoDB.Collection(collection).Indexes().CreateOne(ctx, index)
 		if err != nil {
-			log.Printf("Warning: Failed to create index %s: %v", indexName, err)
+			log.Printf("Warning: Failed to create index %s on %s: %v", indexName, collection, err)
 		} else {
-			log.Printf("Created index: %s", indexName)
+			log.Printf("Created index: %s.%s", collection, indexName)
 		}
 	}
-
-	log.Println("Finished initializing MongoDB indexes")
 }
@@ -0,0 +1,106 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+
+	"circleconnect-search/analysis"
+)
+
+// Embedder turns texts into dense vectors whose cosine similarity reflects
+// how related the texts are
+type Embedder interface {
+	// Model identifies the provider and its settings. Vectors of different
+	// models are not comparable, so documents store the model they were embedded with.
+	Model() string
+
+	// Embed returns one vector per text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// FromEnv builds the embedder selected by EMBEDDING_PROVIDER:
+//   - hashed (default): hashed bag of words and character n-grams, no setup needed
+//   - vectors: averaged word vectors from the text file at EMBEDDING_MODEL_PATH
+//     (GloVe or fastText .vec format), which captures meaning as well as spelling
+//   - remote: an OpenAI-compatible embeddings API at EMBEDDING_URL
+func FromEnv() (Embedder, error) {
+	switch provider := os.Getenv("EMBEDDING_PROVIDER"); provider {
+	case "", "hashed":
+		return Default(), nil
+	case "vectors":
+		return LoadWordVectors(os.Getenv("EMBEDDING_MODEL_PATH"))
+	case "remote":
+		return NewRemote(os.Getenv("EMBEDDING_URL"), os.Getenv("EMBEDDING_MODEL"), os.Getenv("EMBEDDING_API_KEY"))
+	default:
+		return nil, fmt.Errorf("unknown EMBEDDING_PROVIDER %q, expected hashed, vectors or remote", provider)
+	}
+}
+
+// Default returns the hashed embedder, which needs no configuration
+func Default() Embedder {
+	return NewHashed(defaultHashedDimensions)
+}
+
+// MustFromEnv returns the configured embedder, falling back to the hashed
+// embedder when the configuration is invalid
+func MustFromEnv() Embedder {
+	embedder, err := FromEnv()
+	if err != nil {
+		log.Printf("Warning: %v. Falling back to hashed embeddings.", err)
+		return Default()
+	}
+	return embedder
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 when they differ
+// in length or either is zero
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// normalize scales a vector to unit length in place
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range v {
+		v[i] *= scale
+	}
+	return v
+}
+
+// words splits text into analyzed words, dropping stopwords
+func words(text string) []string {
+	var result []string
+	for _, word := range strings.FieldsFunc(analysis.Analyze(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if !analysis.IsStopword(word) {
+			result = append(result, word)
+		}
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"circleconnect-search/ranking"
+)
+
+// Hashed embedding tuning
+const (
+	defaultHashedDimensions = 512
+	trigramWeight           = 0.5 // Character trigrams make "guitarist" close to "guitar"
+)
+
+// Hashed embeds text as a hashed bag of stemmed words and character trigrams.
+// It needs no model and runs anywhere, but only relates texts that share
+// words or word parts.
+type Hashed struct {
+	dimensions int
+}
+
+// NewHashed returns a hashed embedder producing vectors of the given size
+func NewHashed(dimensions int) *Hashed {
+	return &Hashed{dimensions: dimensions}
+}
+
+// Model identifies the embedder and its vector size
+func (h *Hashed) Model() string {
+	return fmt.Sprintf("hashed-ngrams-%d", h.dimensions)
+}
+
+// Embed returns the normalized hashed feature vector of each text
+func (h *Hashed) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, h.dimensions)
+		for _, word := range words(text) {
+			h.add(vector, "w:"+ranking.Stem(word), 1)
+
+			runes := []rune(" " + word + " ")
+			for j := 0; j+3 <= len(runes); j++ {
+				h.add(vector, "t:"+string(runes[j:j+3]), trigramWeight)
+			}
+		}
+		vectors[i] = normalize(vector)
+	}
+	return vectors, nil
+}
+
+// add hashes a feature to a dimension and sign, so collisions cancel out on average
+func (h *Hashed) add(vector []float32, feature string, weight float32) {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(feature))
+	sum := hasher.Sum64()
+
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	vector[sum%uint64(h.dimensions)] += weight
+}
@@ -0,0 +1,81 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Remote embeds text with an OpenAI-compatible embeddings API
+type Remote struct {
+	url    string
+	model  string
+	apiKey string
+	client *http.Client
+}
+
+// NewRemote returns an embedder calling the API at url with the given model
+func NewRemote(url, model, apiKey string) (*Remote, error) {
+	if url == "" {
+		return nil, fmt.Errorf("EMBEDDING_URL is required for the remote provider")
+	}
+	return &Remote{url: url, model: model, apiKey: apiKey, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// Model identifies the remote model
+func (r *Remote) Model() string {
+	return "remote-" + r.model
+}
+
+// Embed sends the texts in one request and returns the normalized vectors
+func (r *Remote) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	payload, err := json.Marshal(map[string]any{"model": r.model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding API returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range body.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding API returned index %d for %d inputs", item.Index, len(texts))
+		}
+		vectors[item.Index] = normalize(item.Embedding)
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("embedding API returned no vector for input %d", i)
+		}
+	}
+	return vectors, nil
+}
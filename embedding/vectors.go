@@ -0,0 +1,101 @@
+package embedding
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"circleconnect-search/analysis"
+)
+
+// WordVectors embeds text as the average of pretrained word vectors, so texts
+// about the same topic are close even without shared words ("learn guitar" and
+// "music lessons"). Words missing from the vocabulary are skipped.
+type WordVectors struct {
+	name       string
+	dimensions int
+	vectors    map[string][]float32
+}
+
+// LoadWordVectors reads word vectors in the GloVe or fastText .vec text format:
+// one word per line followed by its components, with an optional
+// "count dimensions" header line. Words are stored analyzed like the text they
+// are looked up for.
+func LoadWordVectors(path string) (*WordVectors, error) {
+	if path == "" {
+		return nil, fmt.Errorf("EMBEDDING_MODEL_PATH is required for the vectors provider")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	wv := &WordVectors{name: filepath.Base(path), vectors: make(map[string][]float32)}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue // fastText header or blank line
+		}
+
+		if wv.dimensions == 0 {
+			wv.dimensions = len(fields) - 1
+		}
+		if len(fields)-1 != wv.dimensions {
+			return nil, fmt.Errorf("%s:%d: expected %d components, got %d", path, line, wv.dimensions, len(fields)-1)
+		}
+
+		// Words are looked up analyzed, so "Café" and "cafe" share a key. Vector
+		// files list frequent words first, so the first variant is kept.
+		word := analysis.Analyze(fields[0])
+		if _, ok := wv.vectors[word]; ok {
+			continue
+		}
+
+		vector := make([]float32, wv.dimensions)
+		for i, field := range fields[1:] {
+			value, err := strconv.ParseFloat(field, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+			vector[i] = float32(value)
+		}
+		wv.vectors[word] = normalize(vector)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(wv.vectors) == 0 {
+		return nil, fmt.Errorf("%s: no word vectors found", path)
+	}
+	return wv, nil
+}
+
+// Model identifies the vector file and its dimensions
+func (wv *WordVectors) Model() string {
+	return fmt.Sprintf("vectors-%s-%d", wv.name, wv.dimensions)
+}
+
+// Embed returns the normalized average vector of each text's known words
+func (wv *WordVectors) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, wv.dimensions)
+		for _, word := range words(text) {
+			if wordVector, ok := wv.vectors[word]; ok {
+				for j, x := range wordVector {
+					vector[j] += x
+				}
+			}
+		}
+		vectors[i] = normalize(vector)
+	}
+	return vectors, nil
+}
@@ -12,6 +12,8 @@ import (
 
 	"circleconnect-search/controllers"
 	"circleconnect-search/database"
+	"circleconnect-search/embedding"
+	"circleconnect-search/ranking"
 	"circleconnect-search/routes"
 )
 
@@ -30,6 +32,21 @@ func main() {
 		log.Println("Loaded configuration from .env")
 	}
 
+	// Select the embedding provider for semantic search
+	controllers.Embedder = embedding.MustFromEnv()
+	log.Println("Using embedding model:", controllers.Embedder.Model())
+
+	// Load ranking and hybrid fusion weights, keeping the defaults if the file is invalid
+	if rankingConfig, err := ranking.LoadConfig(os.Getenv("RANKING_CONFIG")); err != nil {
+		log.Printf("Warning: Failed to load ranking config: %v. Using default ranking.", err)
+	} else {
+		controllers.RankingConfig = rankingConfig
+		log.Println("Using ranking config:", rankingConfig.Name)
+	}
+
+	// Select the result fields filled in from Postgres
+	controllers.EnrichFields = controllers.EnrichFieldsFromEnv()
+
 	// Check if we need to skip database connections (for development/testing)
 	skipConnections := os.Getenv("SKIP_DB_INIT") == "true"
 
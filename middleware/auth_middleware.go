@@ -38,75 +38,114 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Get the token
-		tokenString := parts[1]
-
-		// Get the JWT secret key from environment variables
-		jwtSecret := os.Getenv("JWT_SECRET_KEY")
-		if jwtSecret == "" {
-			jwtSecret = "default_secret_key" // Only for development
-		}
-
-		// Parse and validate the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(jwtSecret), nil
-		})
-
+		// Validate the token and extract the user
+		user, err := parseToken(parts[1])
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: " + err.Error()})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		// Check if the token is valid
-		if !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
+		// Set user in context
+		c.Set("user", user)
+		c.Next()
+	}
+}
 
-		// Extract claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to extract token claims"})
-			c.Abort()
-			return
+// OptionalAuthMiddleware identifies the user when a valid bearer token is sent,
+// but lets anonymous and invalid requests through unauthenticated
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			if user, err := parseToken(parts[1]); err == nil {
+				c.Set("user", user)
+			}
 		}
 
-		// Check token expiration
-		exp, ok := claims["exp"].(float64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token expiration"})
-			c.Abort()
-			return
-		}
+		c.Next()
+	}
+}
 
-		if time.Now().Unix() > int64(exp) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
-			c.Abort()
-			return
-		}
+// CurrentUser returns the authenticated user set by AuthMiddleware or OptionalAuthMiddleware
+func CurrentUser(c *gin.Context) (User, bool) {
+	userValue, exists := c.Get("user")
+	if !exists {
+		return User{}, false
+	}
+
+	user, ok := userValue.(User)
+	return user, ok
+}
+
+// parseToken validates a JWT and extracts the user from its claims
+func parseToken(tokenString string) (User, error) {
+	// Get the JWT secret key from environment variables
+	jwtSecret := os.Getenv("JWT_SECRET_KEY")
+	if jwtSecret == "" {
+		jwtSecret = "default_secret_key" // Only for development
+	}
 
-		// Extract user information
-		user := User{
-			ID:       claims["id"].(string),
-			Username: claims["username"].(string),
-			Email:    claims["email"].(string),
-			Role:     claims["role"].(string),
+	// Parse and validate the token
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		// Validate the signing method
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
+		return []byte(jwtSecret), nil
+	})
 
-		// Set user in context
-		c.Set("user", user)
-		c.Next()
+	if err != nil {
+		return User{}, fmt.Errorf("Invalid token: %v", err)
+	}
+
+	// Check if the token is valid
+	if !token.Valid {
+		return User{}, fmt.Errorf("Invalid token")
+	}
+
+	// Extract claims
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return User{}, fmt.Errorf("Failed to extract token claims")
+	}
+
+	// Check token expiration
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return User{}, fmt.Errorf("Invalid token expiration")
+	}
+
+	if time.Now().Unix() > int64(exp) {
+		return User{}, fmt.Errorf("Token expired")
+	}
+
+	// Extract user information
+	user := User{}
+	user.ID, _ = claims["id"].(string)
+	user.Username, _ = claims["username"].(string)
+	user.Email, _ = claims["email"].(string)
+	user.Role, _ = claims["role"].(string)
+	if user.ID == "" {
+		return User{}, fmt.Errorf("Invalid token claims")
 	}
+
+	return user, nil
 }
 
-// AdminMiddleware ensures that the user has admin privileges
-func AdminMiddleware() gin.HandlerFunc {
+// Roles with elevated access
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// AdminMiddleware ensures that the user has one of the given roles, admin by default
+func AdminMiddleware(roles ...string) gin.HandlerFunc {
+	if len(roles) == 0 {
+		roles = []string{RoleAdmin}
+	}
+	denied := "Insufficient role: requires " + strings.Join(roles, " or ")
+
 	return func(c *gin.Context) {
 		// Get user from context
 		userValue, exists := c.Get("user")
@@ -123,9 +162,9 @@ func AdminMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Check if user has admin role
-		if user.Role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		// Check if user has an allowed role
+		if !hasRole(user, roles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": denied})
 			c.Abort()
 			return
 		}
@@ -134,6 +173,23 @@ func AdminMiddleware() gin.HandlerFunc {
 	}
 }
 
+// HasRole reports whether the authenticated user has one of the roles. Public
+// handlers use it to unlock options for moderators, like AdminMiddleware does for routes.
+func HasRole(c *gin.Context, roles ...string) bool {
+	user, ok := CurrentUser(c)
+	return ok && hasRole(user, roles)
+}
+
+// hasRole reports whether the user's role is one of roles
+func hasRole(user User, roles []string) bool {
+	for _, role := range roles {
+		if user.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
 // ServiceAuthMiddleware authorizes internal service-to-service communication
 func ServiceAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -145,14 +201,8 @@ func ServiceAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Get expected API key from environment variables
-		expectedAPIKey := os.Getenv("SERVICE_API_KEY")
-		if expectedAPIKey == "" {
-			expectedAPIKey = "default_service_key" // Only for development
-		}
-
 		// Validate API key
-		if apiKey != expectedAPIKey {
+		if apiKey != serviceAPIKey() {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid service API key"})
 			c.Abort()
 			return
@@ -160,4 +210,20 @@ func ServiceAuthMiddleware() gin.HandlerFunc {
 
 		c.Next()
 	}
-} 
\ No newline at end of file
+}
+
+// IsServiceRequest reports whether the request carries a valid service API key.
+// It lets public handlers unlock internal-only options without a separate route.
+func IsServiceRequest(c *gin.Context) bool {
+	apiKey := c.GetHeader("X-Service-API-Key")
+	return apiKey != "" && apiKey == serviceAPIKey()
+}
+
+// serviceAPIKey returns the expected service API key from the environment
+func serviceAPIKey() string {
+	expectedAPIKey := os.Getenv("SERVICE_API_KEY")
+	if expectedAPIKey == "" {
+		expectedAPIKey = "default_service_key" // Only for development
+	}
+	return expectedAPIKey
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SearchEvent is a query-log entry written for every Search and Recommend call
+type SearchEvent struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SearchID    string             `bson:"search_id" json:"search_id"`                 // Returned to the client to tie clicks to this search
+	Endpoint    string             `bson:"endpoint" json:"endpoint"`                   // search, search_all or recommend
+	Query       string             `bson:"query" json:"query"`                         // Normalized query or prefix
+	Filters     map[string]any     `bson:"filters,omitempty" json:"filters,omitempty"` // Filters and pagination applied
+	ResultCount int                `bson:"result_count" json:"result_count"`           // Number of results returned
+	LatencyMs   int64              `bson:"latency_ms" json:"latency_ms"`               // Time spent serving the request
+	CacheHit    bool               `bson:"cache_hit" json:"cache_hit"`                 // Whether the response came from Redis
+	PublicMatch bool               `bson:"public_match" json:"public_match"`           // Whether the query fed suggestions: it matched public content outside a moderator search
+	UserID      string             `bson:"user_id,omitempty" json:"user_id,omitempty"` // Set when the caller is authenticated
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// ClickEvent records a click on a search result
+type ClickEvent struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SearchID    string             `bson:"search_id" json:"search_id" binding:"required"`
+	ContentID   string             `bson:"content_id" json:"content_id" binding:"required"`
+	ContentType ContentType        `bson:"content_type,omitempty" json:"content_type"`
+	Position    int                `bson:"position" json:"position" binding:"required,min=1"` // 1-based rank of the clicked result
+	UserID      string             `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// TrendingTerm is a term ranked by recent search and hashtag activity
+type TrendingTerm struct {
+	Term     string  `bson:"_id" json:"term"`
+	Score    float64 `bson:"score" json:"score"`                           // Time-decayed activity in the window
+	Count    int     `bson:"count" json:"count"`                           // Raw activity in the window
+	Velocity float64 `bson:"velocity,omitempty" json:"velocity,omitempty"` // Window rate relative to the baseline rate
+	Spike    bool    `bson:"spike,omitempty" json:"spike,omitempty"`       // Whether the velocity counts as a spike
+}
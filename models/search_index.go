@@ -32,20 +32,160 @@ type SearchIndex struct {
 	Metadata            map[string]any     `bson:"metadata,omitempty" json:"metadata"`                         // Additional metadata
 	AutocompletePhrases []string           `bson:"autocomplete_phrases,omitempty" json:"autocomplete_phrases"` // Key phrases for autocomplete
 	PopularityScore     float64            `bson:"popularity_score,omitempty" json:"popularity_score"`         // For ranking recommendations
+	Hashtags            []string           `bson:"hashtags,omitempty" json:"hashtags"`                         // Lowercase #tags found in the title and content
+	Mentions            []string           `bson:"mentions,omitempty" json:"mentions"`                         // Lowercase @usernames found in the title and content
+	Lang                string             `bson:"lang,omitempty" json:"lang"`                                 // ISO 639-1 code (en, fr, ar), detected when not supplied
+	Language            string             `bson:"language,omitempty" json:"-"`                                // MongoDB text search language derived from Lang
+	NormalizedText      string             `bson:"normalized_text,omitempty" json:"-"`                         // Analyzed variants of words that normalization changes
+	Visibility          []string           `bson:"visibility,omitempty" json:"visibility"`                     // ACL entries, any one grants access; public when empty
+	ModerationState     string             `bson:"moderation_state,omitempty" json:"moderation_state"`         // visible, pending, hidden or removed; visible when empty
+	NSFW                bool               `bson:"nsfw" json:"nsfw"`                                           // Not safe for work, excluded unless requested; always stored so re-indexing can clear it
+	ModeratedAt         *time.Time         `bson:"moderated_at,omitempty" json:"moderated_at,omitempty"`       // When a moderator last changed the state
+	ModeratedBy         string             `bson:"moderated_by,omitempty" json:"moderated_by,omitempty"`       // Moderator user ID, or "service"
+	Embedding           []float32          `bson:"embedding,omitempty" json:"-"`                               // Unit vector for semantic search
+	EmbeddingModel      string             `bson:"embedding_model,omitempty" json:"-"`                         // Embedder that produced Embedding
+	Username            string             `bson:"username,omitempty" json:"username,omitempty"`               // User documents: lowercase username without @
+	DisplayName         string             `bson:"display_name,omitempty" json:"display_name,omitempty"`       // User documents: name shown on the profile
+	NameTokens          []string           `bson:"name_tokens,omitempty" json:"-"`                             // Accent-folded display name words for prefix matching
+	HiddenFromSearch    bool               `bson:"hidden_from_search" json:"hidden_from_search"`               // User documents: the user opted out of people search
+	Community           *CommunityInfo     `bson:"community,omitempty" json:"community,omitempty"`             // Community documents: size and activity signals
+	CommunityID         string             `bson:"community_id,omitempty" json:"community_id,omitempty"`       // Community the content was posted in
+	ParentPostID        string             `bson:"parent_post_id,omitempty" json:"parent_post_id,omitempty"`   // Comment documents: the post commented on
+	Location            *GeoPoint          `bson:"location,omitempty" json:"location,omitempty"`               // Where a community meets or an event takes place
+}
+
+// GeoPoint is a GeoJSON point. Coordinates are [longitude, latitude].
+type GeoPoint struct {
+	Type        string     `bson:"type" json:"type"`
+	Coordinates [2]float64 `bson:"coordinates" json:"coordinates"`
+}
+
+// Lat returns the point's latitude
+func (p GeoPoint) Lat() float64 {
+	return p.Coordinates[1]
+}
+
+// Lng returns the point's longitude
+func (p GeoPoint) Lng() float64 {
+	return p.Coordinates[0]
+}
+
+// CommunityInfo holds the structured metrics of a community document, used for
+// community filters and ranking
+type CommunityInfo struct {
+	MemberCount int    `bson:"member_count" json:"member_count"`
+	PostsLast7d int    `bson:"posts_last_7d" json:"posts_last_7d"` // Posts created in the last 7 days
+	Category    string `bson:"category,omitempty" json:"category,omitempty"`
+	Location    string `bson:"location,omitempty" json:"location,omitempty"`
+	Joinable    bool   `bson:"joinable" json:"joinable"` // Anyone may join without an invitation
+}
+
+// Visibility ACL entries
+const (
+	VisibilityPublic     = "public"      // Anyone, including anonymous callers
+	VisibilityAuthorOnly = "author_only" // Only the author
+
+	// VisibilityCommunityPrefix is followed by a community ID, e.g. community_members:42
+	VisibilityCommunityPrefix = "community_members:"
+)
+
+// Moderation states
+const (
+	ModerationVisible = "visible"
+	ModerationPending = "pending"
+	ModerationHidden  = "hidden"
+	ModerationRemoved = "removed"
+)
+
+// IsModerationState reports whether state is a known moderation state
+func IsModerationState(state string) bool {
+	switch state {
+	case ModerationVisible, ModerationPending, ModerationHidden, ModerationRemoved:
+		return true
+	}
+	return false
+}
+
+// IsVisible reports whether moderation and the owner allow the document to be listed
+func (s SearchIndex) IsVisible() bool {
+	return (s.ModerationState == "" || s.ModerationState == ModerationVisible) && !s.NSFW && !s.HiddenFromSearch
+}
+
+// IsListed reports whether the document may appear in shared listings and
+// anonymous searches: public, visible and safe for work. listedFilter in the
+// controllers matches the same documents in MongoDB.
+func (s SearchIndex) IsListed() bool {
+	return s.IsPublic() && s.IsVisible()
+}
+
+// IsPublic reports whether anyone may see the document
+func (s SearchIndex) IsPublic() bool {
+	if len(s.Visibility) == 0 {
+		return true
+	}
+	for _, entry := range s.Visibility {
+		if entry == VisibilityPublic {
+			return true
+		}
+	}
+	return false
 }
 
 // SearchResult represents the result of a search query
 type SearchResult struct {
-	ID          string      `json:"id"`
-	ContentID   string      `json:"content_id"`
-	ContentType ContentType `json:"content_type"`
-	Title       string      `json:"title,omitempty"`
-	Snippet     string      `json:"snippet"` // A preview/snippet of the content
-	Author      string      `json:"author,omitempty"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
-	Score       float64     `json:"score"`
-	Highlights  []string    `json:"highlights,omitempty"` // Highlighted parts that matched the query
+	ID          string         `json:"id"`
+	ContentID   string         `json:"content_id"`
+	ContentType ContentType    `json:"content_type"`
+	Title       string         `json:"title,omitempty"`
+	Snippet     string         `json:"snippet"` // A preview/snippet of the content
+	Author      string         `json:"author,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Score       float64        `json:"score"`
+	Highlights  []string       `json:"highlights,omitempty"`       // Highlighted parts that matched the query
+	Moderation  string         `json:"moderation_state,omitempty"` // Only set when moderators include non-visible states
+	NSFW        bool           `json:"nsfw,omitempty"`
+	Community   *CommunityInfo `json:"community,omitempty"`   // Metrics of community results
+	Parent      *ParentRef     `json:"parent,omitempty"`      // Post a comment result belongs to
+	ThreadHits  int            `json:"thread_hits,omitempty"` // Comment hits of the thread this result stands for when collapsed
+	DistanceKm  *float64       `json:"distance_km,omitempty"` // Distance from the near point of a geo search
+	CommunityID string         `json:"community_id,omitempty"`
+	// Fresh details loaded from Postgres when results are served
+	AuthorProfile *AuthorProfile `json:"author_profile,omitempty"`
+	CommunityName string         `json:"community_name,omitempty"`
+	Explanation   *Explanation   `json:"explanation,omitempty"` // Score breakdown, only set in explain mode
+}
+
+// AuthorProfile is the current profile of a result's author
+type AuthorProfile struct {
+	Username    string `json:"username,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+}
+
+// ParentRef is the post a comment search result belongs to. The title is left
+// out when the caller may not see the post.
+type ParentRef struct {
+	ContentID   string `json:"content_id"`
+	CommunityID string `json:"community_id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	URL         string `json:"url"`
+}
+
+// Explanation describes how a search hit's score was computed
+type Explanation struct {
+	MatchedFields   []string           `json:"matched_fields"`          // Indexed fields containing a query term
+	FieldScores     map[string]float64 `json:"field_scores"`            // Approximate text score contribution per field
+	TextScore       float64            `json:"text_score"`              // Text score reported by MongoDB
+	RecencyBoost    float64            `json:"recency_boost"`           // Boost for recently created content
+	PopularityBoost float64            `json:"popularity_boost"`        // Boost derived from popularity_score
+	ActivityBoost   float64            `json:"activity_boost"`          // Boost for large, active communities
+	GeoBoost        float64            `json:"geo_boost,omitempty"`     // Boost for content near the geo search origin
+	FinalScore      float64            `json:"final_score"`             // text_score * (1 + recency_boost + popularity_boost + activity_boost + geo_boost)
+	Similarity      float64            `json:"similarity,omitempty"`    // Embedding similarity in hybrid mode
+	TextRank        int                `json:"text_rank,omitempty"`     // Rank among text hits in hybrid mode
+	SemanticRank    int                `json:"semantic_rank,omitempty"` // Rank among semantic hits in hybrid mode
+	FusedScore      float64            `json:"fused_score,omitempty"`   // Hybrid score from both ranks
 }
 
 // SearchQuery represents a search request
@@ -61,3 +201,23 @@ type SearchQuery struct {
 	SortBy      string     `json:"sort_by,omitempty"`    // Field to sort by
 	SortOrder   string     `json:"sort_order,omitempty"` // asc or desc
 }
+
+// HashtagCount is a hashtag with the number of indexed documents using it
+type HashtagCount struct {
+	Tag   string `bson:"_id" json:"tag"`
+	Count int    `bson:"count" json:"count"`
+}
+
+// Suggestion is an autocomplete entry in the suggestions collection. There is
+// one entry per normalized phrase and scope ("all" or a content type).
+type Suggestion struct {
+	ID         string    `bson:"_id" json:"-"`                     // scope + ":" + normalized phrase
+	Phrase     string    `bson:"phrase" json:"phrase"`             // Display form, as first seen
+	Normalized string    `bson:"normalized" json:"normalized"`     // Lowercase phrase used for deduplication
+	Scope      string    `bson:"scope" json:"scope"`               // "all" or a content type
+	Prefixes   []string  `bson:"prefixes" json:"-"`                // Edge n-grams of the phrase and of each word start
+	Weight     float64   `bson:"weight" json:"weight"`             // Accumulated popularity and query activity
+	Sources    []string  `bson:"sources,omitempty" json:"sources"` // Where the phrase came from (title, tag, hashtag, phrase, query)
+	Authors    []string  `bson:"authors,omitempty" json:"-"`       // Up to 20 authors of the documents that fed the phrase
+	UpdatedAt  time.Time `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RecentSearch is a query an authenticated user ran, one entry per normalized query
+type RecentSearch struct {
+	ID             string    `bson:"_id" json:"-"` // user_id + ":" + normalized query
+	UserID         string    `bson:"user_id" json:"-"`
+	Query          string    `bson:"query" json:"query"` // Normalized query
+	Count          int       `bson:"count" json:"count"` // Times the user ran it
+	LastSearchedAt time.Time `bson:"last_searched_at" json:"last_searched_at"`
+}
+
+// SavedSearch is a named query with filters that a user keeps for later
+type SavedSearch struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    string             `bson:"user_id" json:"-"`
+	Name      string             `bson:"name" json:"name" binding:"required,max=100"`
+	Query     string             `bson:"query" json:"query" binding:"required,max=500"`
+	Filters   map[string]string  `bson:"filters,omitempty" json:"filters,omitempty"` // Search parameters such as type and lang
+	Alert     *SearchAlert       `bson:"alert,omitempty" json:"alert,omitempty"`     // Set when the user subscribed to new matches
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// AlertTerms are the analyzed query terms, #hashtags and @mentions used to
+	// find candidate subscriptions for a newly indexed document
+	AlertTerms []string `bson:"alert_terms,omitempty" json:"-"`
+}
+
+// SearchAlert is a saved search subscription to newly indexed matching content
+type SearchAlert struct {
+	Enabled         bool       `bson:"enabled" json:"enabled"`
+	ThrottleMinutes int        `bson:"throttle_minutes" json:"throttle_minutes"`             // Minimum time between notifications
+	LastSentAt      *time.Time `bson:"last_sent_at,omitempty" json:"last_sent_at,omitempty"` // When the last notification was published
+}
+
+// AlertEvent is published to the notification service when new content matches a saved search
+type AlertEvent struct {
+	Type            string      `json:"type"`
+	UserID          string      `json:"user_id"`
+	SavedSearchID   string      `json:"saved_search_id"`
+	SavedSearchName string      `json:"saved_search_name"`
+	Query           string      `json:"query"`
+	ContentID       string      `json:"content_id"`
+	ContentType     ContentType `json:"content_type"`
+	Title           string      `json:"title,omitempty"`
+	Author          string      `json:"author,omitempty"`
+	MatchedAt       time.Time   `json:"matched_at"`
+}
@@ -0,0 +1,107 @@
+package ranking
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// configJSON is the on-disk form of Config, with the half-life as a duration string
+type configJSON struct {
+	Name             string             `json:"name"`
+	FieldWeights     map[string]float64 `json:"field_weights,omitempty"`
+	RecencyWeight    float64            `json:"recency_weight"`
+	RecencyHalfLife  string             `json:"recency_half_life"`
+	PopularityWeight float64            `json:"popularity_weight"`
+	MemberWeight     float64            `json:"member_weight"`
+	ActivityWeight   float64            `json:"activity_weight"`
+	GeoWeight        float64            `json:"geo_weight"`
+	Fusion           *FusionConfig      `json:"fusion,omitempty"`
+}
+
+// MarshalJSON writes the half-life as a Go duration string such as "720h0m0s"
+func (cfg Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(configJSON{
+		Name:             cfg.Name,
+		FieldWeights:     cfg.FieldWeights,
+		RecencyWeight:    cfg.RecencyWeight,
+		RecencyHalfLife:  cfg.RecencyHalfLife.String(),
+		PopularityWeight: cfg.PopularityWeight,
+		MemberWeight:     cfg.MemberWeight,
+		ActivityWeight:   cfg.ActivityWeight,
+		GeoWeight:        cfg.GeoWeight,
+		Fusion:           &cfg.Fusion,
+	})
+}
+
+// UnmarshalJSON reads a config, keeping the defaults for anything left out
+func (cfg *Config) UnmarshalJSON(data []byte) error {
+	// Settings are decoded over the defaults, so files written before a weight
+	// existed keep its default instead of turning it off
+	defaults := DefaultConfig()
+	fusion := defaults.Fusion
+	raw := configJSON{
+		RecencyWeight:    defaults.RecencyWeight,
+		PopularityWeight: defaults.PopularityWeight,
+		MemberWeight:     defaults.MemberWeight,
+		ActivityWeight:   defaults.ActivityWeight,
+		GeoWeight:        defaults.GeoWeight,
+		Fusion:           &fusion,
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	halfLife := defaults.RecencyHalfLife
+	if raw.RecencyHalfLife != "" {
+		var err error
+		halfLife, err = time.ParseDuration(raw.RecencyHalfLife)
+		if err != nil {
+			return fmt.Errorf("invalid recency_half_life: %w", err)
+		}
+	}
+
+	weights := IndexFieldWeights()
+	for field, weight := range raw.FieldWeights {
+		weights[field] = weight
+	}
+
+	if fusion.Method != FusionRRF && fusion.Method != FusionWeighted {
+		return fmt.Errorf("invalid fusion method %q, expected rrf or weighted", fusion.Method)
+	}
+
+	*cfg = Config{
+		Name:             raw.Name,
+		FieldWeights:     weights,
+		RecencyWeight:    raw.RecencyWeight,
+		RecencyHalfLife:  halfLife,
+		PopularityWeight: raw.PopularityWeight,
+		MemberWeight:     raw.MemberWeight,
+		ActivityWeight:   raw.ActivityWeight,
+		GeoWeight:        raw.GeoWeight,
+		Fusion:           fusion,
+	}
+	return nil
+}
+
+// LoadConfig reads a ranking config from a JSON file. An empty path returns the default.
+func LoadConfig(path string) (Config, error) {
+	if path == "" {
+		return DefaultConfig(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if cfg.Name == "" {
+		cfg.Name = path
+	}
+	return cfg, nil
+}
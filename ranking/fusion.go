@@ -0,0 +1,132 @@
+package ranking
+
+import (
+	"math"
+	"sort"
+)
+
+// Fusion methods for hybrid search
+const (
+	FusionRRF      = "rrf"      // Reciprocal rank fusion
+	FusionWeighted = "weighted" // Weighted sum of min-max normalized scores
+)
+
+// FusionConfig controls how hybrid search merges the text and semantic result lists
+type FusionConfig struct {
+	Method          string             `json:"method"`                     // rrf or weighted
+	RRFK            float64            `json:"rrf_k"`                      // Rank offset dampening top ranks in RRF
+	SemanticWeight  float64            `json:"semantic_weight"`            // Share of the semantic list; text gets the rest
+	SemanticWeights map[string]float64 `json:"semantic_weights,omitempty"` // Per content type overrides of SemanticWeight
+	Candidates      int                `json:"candidates"`                 // Hits taken from each list before fusing
+}
+
+// DefaultFusionConfig returns the fusion used by hybrid search. Names and
+// usernames are matched better by keywords, so users lean on the text list.
+func DefaultFusionConfig() FusionConfig {
+	return FusionConfig{
+		Method:          FusionRRF,
+		RRFK:            60,
+		SemanticWeight:  0.5,
+		SemanticWeights: map[string]float64{"user": 0.2},
+		Candidates:      200,
+	}
+}
+
+// WeightFor returns the semantic weight for a content type
+func (f FusionConfig) WeightFor(contentType string) float64 {
+	if weight, ok := f.SemanticWeights[contentType]; ok {
+		return weight
+	}
+	return f.SemanticWeight
+}
+
+// FusionHit is a hit of one ranked list, best first
+type FusionHit struct {
+	ID          string
+	ContentType string
+	Score       float64
+}
+
+// FusedHit is a hit of the fused list with its rank in each input list (0 when absent)
+type FusedHit struct {
+	ID           string
+	Score        float64
+	TextRank     int
+	SemanticRank int
+}
+
+// Fuse merges the text and semantic lists into one list sorted by fused score,
+// ties by ID. A hit repeated within a list counts once, at its best rank.
+func (f FusionConfig) Fuse(text, semantic []FusionHit) []FusedHit {
+	textScores := f.listScores(text)
+	semanticScores := f.listScores(semantic)
+
+	byID := map[string]*FusedHit{}
+	var fused []*FusedHit
+	add := func(hits []FusionHit, scores []float64, semanticList bool) {
+		for i, hit := range hits {
+			entry, ok := byID[hit.ID]
+			if !ok {
+				entry = &FusedHit{ID: hit.ID}
+				byID[hit.ID] = entry
+				fused = append(fused, entry)
+			}
+
+			if (semanticList && entry.SemanticRank > 0) || (!semanticList && entry.TextRank > 0) {
+				continue
+			}
+
+			weight := f.WeightFor(hit.ContentType)
+			if semanticList {
+				entry.SemanticRank = i + 1
+			} else {
+				entry.TextRank = i + 1
+				weight = 1 - weight
+			}
+			entry.Score += weight * scores[i]
+		}
+	}
+	add(text, textScores, false)
+	add(semantic, semanticScores, true)
+
+	sort.Slice(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		return fused[i].ID < fused[j].ID
+	})
+
+	results := make([]FusedHit, len(fused))
+	for i, hit := range fused {
+		results[i] = *hit
+	}
+	return results
+}
+
+// listScores returns each hit's contribution before weighting: 1/(k + rank) for
+// RRF, or the score min-max normalized to [0, 1] within the list
+func (f FusionConfig) listScores(hits []FusionHit) []float64 {
+	scores := make([]float64, len(hits))
+	if f.Method == FusionWeighted {
+		low, high := math.Inf(1), math.Inf(-1)
+		for _, hit := range hits {
+			low, high = math.Min(low, hit.Score), math.Max(high, hit.Score)
+		}
+		for i, hit := range hits {
+			scores[i] = 1
+			if high > low {
+				scores[i] = (hit.Score - low) / (high - low)
+			}
+		}
+		return scores
+	}
+
+	k := f.RRFK
+	if k <= 0 {
+		k = 60
+	}
+	for i := range hits {
+		scores[i] = 1 / (k + float64(i+1))
+	}
+	return scores
+}
@@ -0,0 +1,123 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFuse(t *testing.T) {
+	rrf := DefaultFusionConfig()
+	weighted := DefaultFusionConfig()
+	weighted.Method = FusionWeighted
+	weighted.SemanticWeight = 0.25
+
+	tests := []struct {
+		name     string
+		config   FusionConfig
+		text     []FusionHit
+		semantic []FusionHit
+		want     []FusedHit
+	}{
+		{
+			name:     "empty inputs",
+			config:   rrf,
+			text:     nil,
+			semantic: nil,
+			want:     []FusedHit{},
+		},
+		{
+			name:     "only a semantic list",
+			config:   rrf,
+			text:     nil,
+			semantic: []FusionHit{{ID: "a"}},
+			want:     []FusedHit{{ID: "a", Score: 0.5 / 61, SemanticRank: 1}},
+		},
+		{
+			// b is second in the text list but also in the semantic list
+			name:     "a document in only one list",
+			config:   rrf,
+			text:     []FusionHit{{ID: "a"}, {ID: "b"}},
+			semantic: []FusionHit{{ID: "b"}},
+			want: []FusedHit{
+				{ID: "b", Score: 0.5/62 + 0.5/61, TextRank: 2, SemanticRank: 1},
+				{ID: "a", Score: 0.5 / 61, TextRank: 1},
+			},
+		},
+		{
+			name:     "duplicate IDs within a list count once at the best rank",
+			config:   rrf,
+			text:     []FusionHit{{ID: "a"}, {ID: "a"}, {ID: "b"}},
+			semantic: []FusionHit{{ID: "b"}, {ID: "b"}},
+			want: []FusedHit{
+				{ID: "b", Score: 0.5/63 + 0.5/61, TextRank: 3, SemanticRank: 1},
+				{ID: "a", Score: 0.5 / 61, TextRank: 1},
+			},
+		},
+		{
+			name:     "per content type weight",
+			config:   rrf,
+			text:     []FusionHit{{ID: "p", ContentType: "post"}},
+			semantic: []FusionHit{{ID: "u", ContentType: "user"}},
+			want: []FusedHit{
+				{ID: "p", Score: 0.5 / 61, TextRank: 1},
+				{ID: "u", Score: 0.2 / 61, SemanticRank: 1},
+			},
+		},
+		{
+			name:     "non-positive rrf_k falls back to 60",
+			config:   FusionConfig{Method: FusionRRF, SemanticWeight: 0.5},
+			text:     []FusionHit{{ID: "a"}},
+			semantic: nil,
+			want:     []FusedHit{{ID: "a", Score: 0.5 / 61, TextRank: 1}},
+		},
+		{
+			// Text scores normalize to 1, 0.5 and 0, semantic ones to 1 and 0,
+			// then text takes 0.75 of each and semantic 0.25
+			name:     "weighted scores are min-max normalized per list",
+			config:   weighted,
+			text:     []FusionHit{{ID: "a", Score: 10}, {ID: "b", Score: 5}, {ID: "c", Score: 0}},
+			semantic: []FusionHit{{ID: "c", Score: 0.9}, {ID: "a", Score: 0.3}},
+			want: []FusedHit{
+				{ID: "a", Score: 0.75, TextRank: 1, SemanticRank: 2},
+				{ID: "b", Score: 0.375, TextRank: 2},
+				{ID: "c", Score: 0.25, TextRank: 3, SemanticRank: 1},
+			},
+		},
+		{
+			name:     "weighted scores that are all equal normalize to one",
+			config:   weighted,
+			text:     []FusionHit{{ID: "a", Score: 3}, {ID: "b", Score: 3}},
+			semantic: []FusionHit{{ID: "c", Score: 0.4}},
+			want: []FusedHit{
+				{ID: "a", Score: 0.75, TextRank: 1},
+				{ID: "b", Score: 0.75, TextRank: 2},
+				{ID: "c", Score: 0.25, SemanticRank: 1},
+			},
+		},
+		{
+			name:     "tied scores are ordered by ID",
+			config:   rrf,
+			text:     []FusionHit{{ID: "b"}},
+			semantic: []FusionHit{{ID: "a"}},
+			want: []FusedHit{
+				{ID: "a", Score: 0.5 / 61, SemanticRank: 1},
+				{ID: "b", Score: 0.5 / 61, TextRank: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.config.Fuse(tt.text, tt.semantic)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Fuse() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if g.ID != w.ID || g.TextRank != w.TextRank || g.SemanticRank != w.SemanticRank || math.Abs(g.Score-w.Score) > 1e-12 {
+					t.Errorf("Fuse()[%d] = %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package ranking
+
+import (
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// earthRadiusKm is the mean Earth radius used for distances
+const earthRadiusKm = 6371.0
+
+// GeoOrigin is the point a geo search is centered on. Documents within RadiusKm
+// get a boost that halves every RadiusKm/2.
+type GeoOrigin struct {
+	Lat      float64
+	Lng      float64
+	RadiusKm float64
+}
+
+// halfDistance is the distance at which the geo boost halves
+func (o GeoOrigin) halfDistance() float64 {
+	return math.Max(o.RadiusKm/2, 0.001)
+}
+
+// DistanceKm returns the great-circle distance between two points
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1, phi2 := lat1*math.Pi/180, lat2*math.Pi/180
+	cosine := math.Sin(phi1)*math.Sin(phi2) + math.Cos(phi1)*math.Cos(phi2)*math.Cos((lng2-lng1)*math.Pi/180)
+	return earthRadiusKm * math.Acos(math.Max(-1, math.Min(1, cosine)))
+}
+
+// GeoBoost mirrors the geo_boost stage for a document at a distance from the origin
+func (cfg Config) GeoBoost(distanceKm float64) float64 {
+	if cfg.Origin == nil {
+		return 0
+	}
+	return cfg.GeoWeight * math.Exp(-math.Ln2*distanceKm/cfg.Origin.halfDistance())
+}
+
+// geoStages returns the stages that compute distance_km from the document's
+// GeoJSON location to the origin, and the distance-decay geo_boost
+func (cfg Config) geoStages() []bson.M {
+	if cfg.Origin == nil {
+		return nil
+	}
+
+	radians := func(degrees any) bson.M {
+		return bson.M{"$degreesToRadians": degrees}
+	}
+	lat := radians(bson.M{"$arrayElemAt": bson.A{"$location.coordinates", 1}})
+	lng := radians(bson.M{"$arrayElemAt": bson.A{"$location.coordinates", 0}})
+	originLat, originLng := cfg.Origin.Lat*math.Pi/180, cfg.Origin.Lng*math.Pi/180
+
+	cosine := bson.M{"$add": bson.A{
+		bson.M{"$multiply": bson.A{math.Sin(originLat), bson.M{"$sin": lat}}},
+		bson.M{"$multiply": bson.A{
+			math.Cos(originLat),
+			bson.M{"$cos": lat},
+			bson.M{"$cos": bson.M{"$subtract": bson.A{lng, originLng}}},
+		}},
+	}}
+
+	return []bson.M{
+		{"$addFields": bson.M{
+			"distance_km": bson.M{"$multiply": bson.A{
+				earthRadiusKm,
+				bson.M{"$acos": bson.M{"$max": bson.A{-1, bson.M{"$min": bson.A{1, cosine}}}}},
+			}},
+		}},
+		{"$addFields": bson.M{
+			"geo_boost": bson.M{"$multiply": bson.A{
+				cfg.GeoWeight,
+				bson.M{"$exp": bson.M{"$multiply": bson.A{-math.Ln2 / cfg.Origin.halfDistance(), "$distance_km"}}},
+			}},
+		}},
+	}
+}
+
+// GeoFilter matches documents located within the origin's radius
+func (o GeoOrigin) GeoFilter() bson.M {
+	return bson.M{"location": bson.M{"$geoWithin": bson.M{
+		"$centerSphere": bson.A{bson.A{o.Lng, o.Lat}, o.RadiusKm / earthRadiusKm},
+	}}}
+}
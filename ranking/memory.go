@@ -0,0 +1,108 @@
+package ranking
+
+import (
+	"sort"
+	"time"
+
+	"circleconnect-search/analysis"
+	"circleconnect-search/models"
+)
+
+// ScoredDocument is a document ranked outside of MongoDB
+type ScoredDocument struct {
+	Document        models.SearchIndex
+	TextScore       float64
+	RecencyBoost    float64
+	PopularityBoost float64
+	ActivityBoost   float64
+	GeoBoost        float64
+	Score           float64
+}
+
+// Rank scores documents in memory the way the search pipeline does for an
+// anonymous caller: only listed documents are candidates, every #hashtag and
+// @mention must match, and like $text a document matches when any remaining
+// query term appears in a weighted field. Queries without words rank every
+// candidate by the boosts alone. It is used for offline evaluation against
+// fixtures, so results are approximate.
+func (cfg Config) Rank(docs []models.SearchIndex, query string, contentType string, now time.Time) []ScoredDocument {
+	parsed := analysis.ParseEntityQuery(query)
+	terms := QueryTerms(parsed.Text)
+
+	var ranked []ScoredDocument
+	for _, doc := range docs {
+		if contentType != "" && string(doc.ContentType) != contentType {
+			continue
+		}
+		if !doc.IsListed() || !matchesEntities(doc, parsed) {
+			continue
+		}
+
+		textScore := 1.0
+		if len(terms) > 0 {
+			textScore = 0
+			for _, score := range cfg.FieldScores(doc, terms) {
+				textScore += score
+			}
+			if textScore == 0 {
+				continue
+			}
+		}
+
+		recency := cfg.RecencyBoost(doc.CreatedAt, now)
+		popularity := cfg.PopularityBoost(doc.PopularityScore)
+		activity := cfg.ActivityBoost(doc.Community)
+		geo := 0.0
+		if cfg.Origin != nil && doc.Location != nil {
+			geo = cfg.GeoBoost(DistanceKm(cfg.Origin.Lat, cfg.Origin.Lng, doc.Location.Lat(), doc.Location.Lng()))
+		}
+		ranked = append(ranked, ScoredDocument{
+			Document:        doc,
+			TextScore:       textScore,
+			RecencyBoost:    recency,
+			PopularityBoost: popularity,
+			ActivityBoost:   activity,
+			GeoBoost:        geo,
+			Score:           FinalScore(textScore, Boosts{Recency: recency, Popularity: popularity, Activity: activity, Geo: geo}),
+		})
+	}
+
+	// Mirror the pipeline's sort: score descending, newest first, then a stable tie-break
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		if !ranked[i].Document.CreatedAt.Equal(ranked[j].Document.CreatedAt) {
+			return ranked[i].Document.CreatedAt.After(ranked[j].Document.CreatedAt)
+		}
+		return ranked[i].Document.ContentID < ranked[j].Document.ContentID
+	})
+
+	return ranked
+}
+
+// matchesEntities reports whether the document has every #hashtag of the query
+// and mentions or is authored by every @user, as the search filter requires
+func matchesEntities(doc models.SearchIndex, parsed analysis.EntityQuery) bool {
+	for _, hashtag := range parsed.Hashtags {
+		if !containsValue(doc.Hashtags, hashtag) {
+			return false
+		}
+	}
+	for _, mention := range parsed.Mentions {
+		if !containsValue(doc.Mentions, mention) && doc.Author != mention {
+			return false
+		}
+	}
+	return true
+}
+
+// containsValue reports whether values contains value
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,338 @@
+package ranking
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"circleconnect-search/models"
+)
+
+// TextIndexWeights are the per-field weights of the text_search_index. The index
+// is created from them, so ranking and explain output never drift from it.
+var TextIndexWeights = bson.D{
+	{Key: "title", Value: 10},
+	{Key: "tags", Value: 5},
+	{Key: "autocomplete_phrases", Value: 3},
+	{Key: "content", Value: 1},
+	{Key: "normalized_text", Value: 1}, // Accent-folded and Arabic-normalized word variants
+}
+
+// Config controls how the Mongo text score is combined with recency and popularity
+type Config struct {
+	Name             string
+	FieldWeights     map[string]float64 // Per-field text weights
+	RecencyWeight    float64            // Maximum boost for brand new content
+	RecencyHalfLife  time.Duration      // Age at which the recency boost halves
+	PopularityWeight float64            // Multiplier for log10(1 + popularity_score)
+	MemberWeight     float64            // Multiplier for log10(1 + member_count) of communities
+	ActivityWeight   float64            // Multiplier for log10(1 + posts_last_7d) of communities
+	GeoWeight        float64            // Maximum boost for content at the geo search origin
+	Origin           *GeoOrigin         // Geo search origin of the current request, if any
+	Fusion           FusionConfig       // How hybrid search merges text and semantic hits
+}
+
+// DefaultConfig returns the ranking used by the Search endpoint. Recency and
+// popularity are off, so text matches rank by text score alone unless a
+// RANKING_CONFIG turns them on.
+func DefaultConfig() Config {
+	return Config{
+		Name:             "default",
+		FieldWeights:     IndexFieldWeights(),
+		RecencyWeight:    0,
+		RecencyHalfLife:  30 * 24 * time.Hour,
+		PopularityWeight: 0,
+		MemberWeight:     0.3,
+		ActivityWeight:   0.3,
+		GeoWeight:        0.5,
+		Fusion:           DefaultFusionConfig(),
+	}
+}
+
+// IndexFieldWeights returns the weights the text_search_index was created with
+func IndexFieldWeights() map[string]float64 {
+	weights := make(map[string]float64, len(TextIndexWeights))
+	for _, field := range TextIndexWeights {
+		switch v := field.Value.(type) {
+		case int:
+			weights[field.Key] = float64(v)
+		case float64:
+			weights[field.Key] = v
+		}
+	}
+	return weights
+}
+
+// ScoreStages returns the aggregation stages that compute text_score, the boosts
+// and the final score. With textMatch they must directly follow a $match stage
+// that uses $text; without it every document gets a text_score of 1 so that
+// filter-only searches (such as #hashtag queries) rank by the boosts alone.
+func (cfg Config) ScoreStages(now time.Time, textMatch bool) []bson.M {
+	halfLifeMs := float64(cfg.RecencyHalfLife.Milliseconds())
+	if halfLifeMs <= 0 {
+		halfLifeMs = 1
+	}
+
+	var textScore any = 1
+	if textMatch {
+		textScore = bson.M{"$meta": "textScore"}
+	}
+
+	stages := []bson.M{
+		{"$addFields": bson.M{"text_score": textScore}},
+		{"$addFields": bson.M{
+			"recency_boost": bson.M{"$multiply": bson.A{
+				cfg.RecencyWeight,
+				bson.M{"$exp": bson.M{"$multiply": bson.A{
+					-math.Ln2 / halfLifeMs,
+					bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{now, "$created_at"}}}},
+				}}},
+			}},
+			"popularity_boost": bson.M{"$multiply": bson.A{
+				cfg.PopularityWeight,
+				bson.M{"$log10": bson.M{"$add": bson.A{
+					1,
+					bson.M{"$max": bson.A{0, bson.M{"$ifNull": bson.A{"$popularity_score", 0}}}},
+				}}},
+			}},
+		}},
+		{"$addFields": bson.M{
+			"activity_boost": bson.M{"$add": bson.A{
+				logBoost(cfg.MemberWeight, "$community.member_count"),
+				logBoost(cfg.ActivityWeight, "$community.posts_last_7d"),
+			}},
+		}},
+	}
+	stages = append(stages, cfg.geoStages()...)
+	return append(stages, bson.M{"$addFields": bson.M{
+		"score": bson.M{"$multiply": bson.A{
+			"$text_score",
+			bson.M{"$add": bson.A{
+				1, "$recency_boost", "$popularity_boost", "$activity_boost",
+				bson.M{"$ifNull": bson.A{"$geo_boost", 0}},
+			}},
+		}},
+	}})
+}
+
+// scoreSort orders ranked documents by score. Ties, such as every hit of a
+// filter-only #hashtag or @mention search without boosts, list newer content first.
+var scoreSort = bson.D{{Key: "score", Value: -1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: 1}}
+
+// logBoost returns weight * log10(1 + field), treating missing and negative values as 0
+func logBoost(weight float64, field string) bson.M {
+	return bson.M{"$multiply": bson.A{
+		weight,
+		bson.M{"$log10": bson.M{"$add": bson.A{
+			1,
+			bson.M{"$max": bson.A{0, bson.M{"$ifNull": bson.A{field, 0}}}},
+		}}},
+	}}
+}
+
+// Pipeline returns the full search pipeline: the filter, the ranking stages,
+// a stable sort on the final score and pagination
+func (cfg Config) Pipeline(filter bson.M, now time.Time, skip, limit int64) []bson.M {
+	_, textMatch := filter["$text"]
+	pipeline := []bson.M{{"$match": filter}}
+	pipeline = append(pipeline, cfg.ScoreStages(now, textMatch)...)
+	return append(pipeline,
+		bson.M{"$sort": scoreSort},
+		bson.M{"$skip": skip},
+		bson.M{"$limit": limit},
+		bson.M{"$project": bson.M{"embedding": 0}}, // Vectors are large and never returned
+	)
+}
+
+// CollapsedPipeline is Pipeline with the comment hits of each post grouped into
+// the best scoring one, which records how many hits it stands for in
+// thread_hits. Pagination applies to the collapsed list.
+func (cfg Config) CollapsedPipeline(filter bson.M, now time.Time, skip, limit int64) []bson.M {
+	_, textMatch := filter["$text"]
+	pipeline := []bson.M{{"$match": filter}}
+	pipeline = append(pipeline, cfg.ScoreStages(now, textMatch)...)
+	threadKey := bson.M{"$cond": bson.A{
+		bson.M{"$and": bson.A{
+			bson.M{"$eq": bson.A{"$content_type", models.Comment}},
+			bson.M{"$gt": bson.A{"$parent_post_id", nil}},
+		}},
+		bson.M{"$concat": bson.A{"post:", "$parent_post_id"}},
+		bson.M{"$toString": "$_id"},
+	}}
+	return append(pipeline,
+		bson.M{"$project": bson.M{"embedding": 0}},
+		bson.M{"$sort": scoreSort},
+		bson.M{"$group": bson.M{"_id": threadKey, "doc": bson.M{"$first": "$$ROOT"}, "thread_hits": bson.M{"$sum": 1}}},
+		bson.M{"$replaceRoot": bson.M{"newRoot": bson.M{"$mergeObjects": bson.A{"$doc", bson.M{"thread_hits": "$thread_hits"}}}}},
+		bson.M{"$sort": scoreSort},
+		bson.M{"$skip": skip},
+		bson.M{"$limit": limit},
+	)
+}
+
+// RecencyBoost mirrors the recency_boost stage for a single document
+func (cfg Config) RecencyBoost(createdAt, now time.Time) float64 {
+	if cfg.RecencyHalfLife <= 0 {
+		return 0
+	}
+	age := math.Max(0, float64(now.Sub(createdAt).Milliseconds()))
+	return cfg.RecencyWeight * math.Exp(-math.Ln2*age/float64(cfg.RecencyHalfLife.Milliseconds()))
+}
+
+// PopularityBoost mirrors the popularity_boost stage for a single document
+func (cfg Config) PopularityBoost(popularity float64) float64 {
+	return cfg.PopularityWeight * math.Log10(1+math.Max(0, popularity))
+}
+
+// ActivityBoost mirrors the activity_boost stage for a single document; only
+// communities have members and recent posts
+func (cfg Config) ActivityBoost(community *models.CommunityInfo) float64 {
+	if community == nil {
+		return 0
+	}
+	return cfg.MemberWeight*math.Log10(1+math.Max(0, float64(community.MemberCount))) +
+		cfg.ActivityWeight*math.Log10(1+math.Max(0, float64(community.PostsLast7d)))
+}
+
+// Boosts are the score boosts of a document, each added to 1 to multiply the text score
+type Boosts struct {
+	Recency    float64
+	Popularity float64
+	Activity   float64
+	Geo        float64
+}
+
+// FinalScore combines a text score with the boosts the same way the pipeline does
+func FinalScore(textScore float64, boosts Boosts) float64 {
+	return textScore * (1 + boosts.Recency + boosts.Popularity + boosts.Activity + boosts.Geo)
+}
+
+// QueryTerms splits a $text search string into the lowercase terms that can match.
+// Negated terms are dropped and quoted phrases are split into their words.
+func QueryTerms(query string) []string {
+	var terms []string
+	for _, field := range strings.Fields(query) {
+		if strings.HasPrefix(field, "-") {
+			continue
+		}
+		terms = append(terms, Tokenize(field)...)
+	}
+	return terms
+}
+
+// Tokenize lowercases text and splits it on anything that isn't a letter or number
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Stem strips common English suffixes so that "posts" and "post" compare equal.
+// It is a rough stand-in for the Snowball stemmer used by Mongo text indexes.
+func Stem(word string) string {
+	for _, suffix := range []string{"ing", "es", "ed", "s"} {
+		if len(word) > len(suffix)+2 && strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+// FieldScore approximates Mongo's text score for one field. It follows the same
+// shape as the server: repeated occurrences add diminishing weight, the result is
+// scaled by term density, and an exact whole-field match gets a small bonus.
+func FieldScore(text string, terms []string, weight float64) float64 {
+	tokens := Tokenize(text)
+	if len(tokens) == 0 || len(terms) == 0 || weight == 0 {
+		return 0
+	}
+
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[Stem(term)] = true
+	}
+
+	type termStats struct {
+		freq  float64
+		count int
+	}
+	stats := make(map[string]*termStats)
+	for _, token := range tokens {
+		stemmed := Stem(token)
+		if !wanted[stemmed] {
+			continue
+		}
+		s, ok := stats[stemmed]
+		if !ok {
+			s = &termStats{}
+			stats[stemmed] = s
+		}
+		s.freq += 1 / math.Pow(2, float64(s.count))
+		s.count++
+	}
+
+	score := 0.0
+	for term, s := range stats {
+		coeff := 0.5*float64(s.count)/float64(len(tokens)) + 0.5
+		adjustment := 1.0
+		if strings.ToLower(strings.TrimSpace(text)) == term {
+			adjustment = 1.1
+		}
+		score += weight * s.freq * coeff * adjustment
+	}
+	return score
+}
+
+// FieldScores returns the approximate text score contribution of every weighted field
+func (cfg Config) FieldScores(doc models.SearchIndex, terms []string) map[string]float64 {
+	scores := make(map[string]float64)
+	for field, weight := range cfg.FieldWeights {
+		var score float64
+		switch field {
+		case "title":
+			score = FieldScore(doc.Title, terms, weight)
+		case "content":
+			score = FieldScore(doc.Content, terms, weight)
+		case "tags":
+			for _, tag := range doc.Tags {
+				score += FieldScore(tag, terms, weight)
+			}
+		case "normalized_text":
+			score = FieldScore(doc.NormalizedText, terms, weight)
+		case "autocomplete_phrases":
+			for _, phrase := range doc.AutocompletePhrases {
+				score += FieldScore(phrase, terms, weight)
+			}
+		}
+		if score > 0 {
+			scores[field] = score
+		}
+	}
+	return scores
+}
+
+// Explain breaks a ranked document's score down into its components
+func (cfg Config) Explain(doc models.SearchIndex, terms []string, textScore float64, boosts Boosts) *models.Explanation {
+	fieldScores := cfg.FieldScores(doc, terms)
+
+	matched := make([]string, 0, len(fieldScores))
+	for _, field := range TextIndexWeights {
+		if _, ok := fieldScores[field.Key]; ok {
+			matched = append(matched, field.Key)
+		}
+	}
+
+	return &models.Explanation{
+		MatchedFields:   matched,
+		FieldScores:     fieldScores,
+		TextScore:       textScore,
+		RecencyBoost:    boosts.Recency,
+		PopularityBoost: boosts.Popularity,
+		ActivityBoost:   boosts.Activity,
+		GeoBoost:        boosts.Geo,
+		FinalScore:      FinalScore(textScore, boosts),
+	}
+}
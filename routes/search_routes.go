@@ -14,15 +14,32 @@ var searchController = new(controllers.SearchController)
 func SetupRoutes(r *gin.Engine) {
 	// Public routes
 	search := r.Group("/api/search")
+	search.Use(middleware.OptionalAuthMiddleware())
 	{
 		// Search endpoint - public access for basic searches
 		search.GET("", searchController.Search)
 
+		// Scoped endpoints - search boxes of community pages and profiles
+		search.GET("/communities/:id", searchController.SearchCommunity)
+		search.GET("/authors/:id", searchController.SearchAuthor)
+
+		// Grouped endpoint - top hits of every content type for the global search dropdown
+		search.GET("/all", searchController.SearchAll)
+
 		// Recommend endpoint - for autocomplete suggestions
 		search.GET("/recommend", searchController.Recommend)
 
 		// Trending endpoint - for popular search terms
 		search.GET("/trending", searchController.TrendingSearches)
+
+		// Hashtags endpoint - hashtag discovery by prefix with usage counts
+		search.GET("/hashtags", searchController.Hashtags)
+
+		// Similar endpoint - "more like this" for related content widgets
+		search.GET("/similar/:type/:id", searchController.Similar)
+
+		// Click endpoint - records which result was opened for a search_id
+		search.POST("/click", searchController.Click)
 	}
 
 	// Protected routes - require authentication
@@ -31,6 +48,24 @@ func SetupRoutes(r *gin.Engine) {
 	{
 		// Advanced search with filters - may be restricted based on user role
 		protected.GET("/advanced", searchController.Search)
+
+		// Personal search history and saved searches
+		protected.GET("/me/recent", searchController.RecentSearches)
+		protected.DELETE("/me/recent", searchController.ClearRecentSearches)
+		protected.GET("/me/saved", searchController.SavedSearches)
+		protected.POST("/me/saved", searchController.SaveSearch)
+		protected.DELETE("/me/saved/:id", searchController.DeleteSavedSearch)
+
+		// Alerts for new content matching a saved search
+		protected.PUT("/me/saved/:id/alert", searchController.EnableSearchAlert)
+		protected.DELETE("/me/saved/:id/alert", searchController.DisableSearchAlert)
+	}
+
+	// Moderation routes - moderators change moderation state with their own token
+	moderation := r.Group("/api/search/moderation")
+	moderation.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware(middleware.RoleAdmin, middleware.RoleModerator))
+	{
+		moderation.POST("", searchController.Moderate)
 	}
 
 	// Admin routes - for content indexing, only internal services should access these
@@ -43,6 +78,23 @@ func SetupRoutes(r *gin.Engine) {
 		// Delete content from the index
 		admin.DELETE("/index/:id", searchController.Delete)
 
+		// Change moderation state in bulk on behalf of a moderation service
+		admin.POST("/moderation", searchController.Moderate)
+
+		// Rebuild the autocomplete store from the index and query log
+		admin.POST("/suggestions/rebuild", searchController.RebuildSuggestions)
+
+		// Embed documents missing an embedding from the current model
+		admin.POST("/embeddings/rebuild", searchController.RebuildEmbeddings)
+
+		// Manage terms that must never appear in trending
+		admin.POST("/trending/blocked", searchController.BlockTrendingTerms)
+		admin.DELETE("/trending/blocked/:term", searchController.UnblockTrendingTerm)
+
+		// Erase or export everything held about a user (account deletion, data requests)
+		admin.DELETE("/users/:userId", searchController.EraseUserData)
+		admin.GET("/users/:userId/export", searchController.ExportUserData)
+
 		// Batch operations could be added here
 	}
 }